@@ -3,15 +3,20 @@ package api
 type HAComponent string
 
 var (
-	HAComponentSensor HAComponent = "sensor"
-	HAComponentNumber HAComponent = "number"
-	HAComponentSelect HAComponent = "select"
+	HAComponentSensor       HAComponent = "sensor"
+	HAComponentBinarySensor HAComponent = "binary_sensor"
+	HAComponentNumber       HAComponent = "number"
+	HAComponentSelect       HAComponent = "select"
+	HAComponentSwitch       HAComponent = "switch"
+	HAComponentText         HAComponent = "text"
+	HAComponentClimate      HAComponent = "climate"
+	HAComponentButton       HAComponent = "button"
 )
 
 type HASensorDiscovery struct {
 	Name                string    `json:"name,omitempty"`
 	UniqueID            string    `json:"unique_id,omitempty"`
-	StateTopic          string    `json:"state_topic"`
+	StateTopic          string    `json:"state_topic,omitempty"`
 	UnitOfMeasurement   string    `json:"unit_of_measurement,omitempty"`
 	DeviceClass         string    `json:"device_class,omitempty"`
 	StateClass          string    `json:"state_class,omitempty"`
@@ -22,13 +27,40 @@ type HASensorDiscovery struct {
 	Device              *HADevice `json:"device,omitempty"`
 	ExpireAfter         int       `json:"expire_after,omitempty"`
 	Icon                string    `json:"icon,omitempty"`
+	EntityCategory      string    `json:"entity_category,omitempty"`
 	JSONAttributesTopic string    `json:"json_attributes_topic,omitempty"`
 	CommandTopic        string    `json:"command_topic,omitempty"`
+	CommandTemplate     string    `json:"command_template,omitempty"`
+	PayloadOn           string    `json:"payload_on,omitempty"`
+	PayloadOff          string    `json:"payload_off,omitempty"`
+	PayloadPress        string    `json:"payload_press,omitempty"`
+	StateOn             string    `json:"state_on,omitempty"`
+	StateOff            string    `json:"state_off,omitempty"`
+	Pattern             string    `json:"pattern,omitempty"`
 	Minimum             float64   `json:"min,omitempty"`
 	Maximum             float64   `json:"max,omitempty"`
 	Step                float64   `json:"step,omitempty"`
 	Mode                string    `json:"mode,omitempty"`
 	Options             []string  `json:"options,omitempty"`
+
+	// Climate entities
+	CurrentTemperatureTopic string   `json:"current_temperature_topic,omitempty"`
+	TemperatureStateTopic   string   `json:"temperature_state_topic,omitempty"`
+	TemperatureCommandTopic string   `json:"temperature_command_topic,omitempty"`
+	TemperatureUnit         string   `json:"temperature_unit,omitempty"`
+	MinTemp                 float64  `json:"min_temp,omitempty"`
+	MaxTemp                 float64  `json:"max_temp,omitempty"`
+	TempStep                float64  `json:"temp_step,omitempty"`
+	Modes                   []string `json:"modes,omitempty"`
+	ModeStateTopic          string   `json:"mode_state_topic,omitempty"`
+	ModeStateTemplate       string   `json:"mode_state_template,omitempty"`
+	ModeCommandTopic        string   `json:"mode_command_topic,omitempty"`
+	ModeCommandTemplate     string   `json:"mode_command_template,omitempty"`
+	ActionTopic             string   `json:"action_topic,omitempty"`
+	ActionTemplate          string   `json:"action_template,omitempty"`
+	PresetModes             []string `json:"preset_modes,omitempty"`
+	PresetModeStateTopic    string   `json:"preset_mode_state_topic,omitempty"`
+	PresetModeCommandTopic  string   `json:"preset_mode_command_topic,omitempty"`
 }
 
 type HADevice struct {
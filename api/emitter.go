@@ -7,11 +7,11 @@ import (
 type Emitter interface {
 	Emit(ctx context.Context, name string, message *Message) error
 	EmitHADiscovery(ctx context.Context, component HAComponent, message HASensorDiscovery) error
-}
-
-// EmitterFunc allows a plain function to be used as an Emitter
-type EmitterFunc func(ctx context.Context, name string, message *Message) error
-
-func (e EmitterFunc) Emit(ctx context.Context, name string, message *Message) error {
-	return e(ctx, name, message)
+	// RemoveHADiscovery removes an entity announced by EmitHADiscovery
+	RemoveHADiscovery(ctx context.Context, component HAComponent, uniqueID string) error
+	// StateTopic returns the topic Emit publishes message to
+	StateTopic(name string, message *Message) string
+	// CommandTopic returns the topic on which commands of the message's
+	// type are received for the message's room
+	CommandTopic(name string, message *Message) string
 }
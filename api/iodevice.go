@@ -0,0 +1,17 @@
+package api
+
+// IODevice is the JSON payload of the state topic of a wireless room unit or
+// sensor, e.g. ezr/eg/1/state/iodevice_1. Fields the controller doesn't
+// report are left out.
+type IODevice struct {
+	Nr             int     `json:"nr"`
+	Type           *int    `json:"type,omitempty"`
+	ID             *int    `json:"id,omitempty"`
+	Room           *int    `json:"room,omitempty"`
+	BatteryLow     *bool   `json:"battery_low,omitempty"`
+	SignalStrength *int    `json:"signal_strength,omitempty"`
+	ComError       *bool   `json:"com_error,omitempty"`
+	State          *int    `json:"state,omitempty"`
+	VersionHW      *string `json:"version_hw,omitempty"`
+	VersionSW      *string `json:"version_sw,omitempty"`
+}
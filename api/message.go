@@ -1,13 +1,27 @@
 package api
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type Message struct {
 	Room int
-	Type string
-	Data string
+	// RoomName is the slug of the room's name, see Slug. It is set if the
+	// room was addressed by name and used for name-based state topics.
+	RoomName string
+	Type     string
+	Data     string
 }
 
+// All addresses every room of a device or every device when used as room
+// or device segment of a command topic, e.g. ezr/all/all/set/heatarea_mode.
+const All = "all"
+
+// DeviceRoom is the room of messages which apply to the whole device rather
+// than a heat area, e.g. ezr/eg/0/set/vacation.
+const DeviceRoom = 0
+
 type RoomDiscovery struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
@@ -26,3 +40,35 @@ type ClimateDiscovery struct {
 func FormatFloat(f float64) string {
 	return fmt.Sprintf("%.2f", f)
 }
+
+// OnOff formats a flag of the device as "on" or "off".
+func OnOff(v int) string {
+	if v == 0 {
+		return "off"
+	}
+	return "on"
+}
+
+var umlautReplacer = strings.NewReplacer(
+	"ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss",
+	"Ä", "Ae", "Ö", "Oe", "Ü", "Ue",
+)
+
+// Slug turns a room name into a form usable as topic segment, e.g.
+// "Wohnzimmer Süd" becomes "wohnzimmer_sued".
+func Slug(name string) string {
+	name = strings.ToLower(umlautReplacer.Replace(name))
+
+	var b strings.Builder
+	underscore := false
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
@@ -0,0 +1,41 @@
+package api
+
+// Redacted replaces secrets in published state.
+const Redacted = "********"
+
+// Meta is the JSON payload of the diagnostics of a device published to
+// e.g. ezr/eg/0/state/meta. Fields the controller doesn't report are left
+// out, secrets are replaced by Redacted.
+type Meta struct {
+	ID         *string      `json:"id,omitempty"`
+	Type       *string      `json:"type,omitempty"`
+	Name       *string      `json:"name,omitempty"`
+	ErrorCount *int         `json:"error_count,omitempty"`
+	DateTime   *string      `json:"date_time,omitempty"`
+	TimeZone   *int         `json:"time_zone,omitempty"`
+	NTPSync    *bool        `json:"ntp_sync,omitempty"`
+	VersionSTM *string      `json:"version_sw_stm,omitempty"`
+	VersionETH *string      `json:"version_sw_eth,omitempty"`
+	VersionHW  *string      `json:"version_hw,omitempty"`
+	Network    *MetaNetwork `json:"network,omitempty"`
+	Cloud      *MetaCloud   `json:"cloud,omitempty"`
+	ExpertCode *string      `json:"expert_code,omitempty"`
+}
+
+type MetaNetwork struct {
+	MAC     *string `json:"mac,omitempty"`
+	DHCP    *bool   `json:"dhcp,omitempty"`
+	IPv4    *string `json:"ipv4,omitempty"`
+	Netmask *string `json:"netmask,omitempty"`
+	Gateway *string `json:"gateway,omitempty"`
+	DNS     *string `json:"dns,omitempty"`
+	IPv6    *string `json:"ipv6,omitempty"`
+}
+
+type MetaCloud struct {
+	UserID   *string `json:"user_id,omitempty"`
+	Password *string `json:"password,omitempty"`
+	Server   *string `json:"server,omitempty"`
+	Active   *bool   `json:"active,omitempty"`
+	State    *string `json:"state,omitempty"`
+}
@@ -0,0 +1,276 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+const (
+	availabilityOnline  = "online"
+	availabilityOffline = "offline"
+)
+
+// Client is a single MQTT connection that can be shared by an Emitter and
+// a Listener, so that the bridge shows up as one session on the broker and
+// its Last Will reflects the state of the whole bridge.
+type Client struct {
+	sync.Mutex
+	connectionDetails
+	mqttGroup             string
+	mqttClientID          string
+	mqttPersistentSession bool
+	mqttSessionExpiry     time.Duration
+
+	conn          *autopaho.ConnectionManager
+	closed        bool
+	router        *paho.StandardRouter
+	subscriptions map[string]paho.MessageHandler
+}
+
+func NewClient(opts ...Opt[Client]) *Client {
+	c := new(Client)
+	for _, opt := range opts {
+		opt(c)
+	}
+	ensureClientDefaults(c)
+	return c
+}
+
+func ensureClientDefaults(c *Client) {
+	if c.mqttBrokerUrls == nil {
+		u, err := url.Parse("mqtt://127.0.0.1:1883/")
+		if err != nil {
+			panic(err)
+		}
+		c.mqttBrokerUrls = []*url.URL{u}
+	}
+	if c.mqttPrefix == "" {
+		c.mqttPrefix = "ezr"
+	}
+	if c.mqttGroup == "" {
+		c.mqttGroup = "ezr2mqtt"
+	}
+	if c.mqttClientID == "" {
+		c.mqttClientID = fmt.Sprintf("%s-%s", c.mqttGroup, stableSuffix())
+	}
+	if c.mqttConnectTimeout == 0 {
+		c.mqttConnectTimeout = 10 * time.Second
+	}
+	if c.mqttConnectRetryDelay == 0 {
+		c.mqttConnectRetryDelay = 1 * time.Second
+	}
+	if c.mqttKeepAliveInterval == 0 {
+		c.mqttKeepAliveInterval = 10
+	}
+	c.router = paho.NewStandardRouter()
+	c.subscriptions = make(map[string]paho.MessageHandler)
+}
+
+// AvailabilityTopic is the topic the client publishes "online" to once
+// connected. The broker publishes "offline" as Last Will if the connection
+// is lost, e.g. ezr/availability.
+func (c *Client) AvailabilityTopic() string {
+	return fmt.Sprintf("%s/availability", c.mqttPrefix)
+}
+
+// Connect establishes the connection to the broker unless this was already
+// done and waits until it is up.
+func (c *Client) Connect(ctx context.Context) error {
+	conn, err := c.ensureConnection(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.AwaitConnection(ctx)
+}
+
+// ensureConnection creates the connection if it doesn't exist yet and waits
+// for it to come up. An existing connection is returned right away, even if
+// it is currently down, so that publishing fails fast instead of blocking.
+// Once disconnected the client doesn't connect again.
+func (c *Client) ensureConnection(ctx context.Context) (*autopaho.ConnectionManager, error) {
+	c.Lock()
+	if c.conn != nil {
+		defer c.Unlock()
+		return c.conn, nil
+	}
+	if c.closed {
+		c.Unlock()
+		return nil, errors.New("client is disconnected")
+	}
+
+	var sessionExpiry uint32
+	if c.mqttPersistentSession {
+		sessionExpiry = uint32(c.mqttSessionExpiry.Seconds())
+	}
+
+	conn, err := autopaho.NewConnection(context.Background(), autopaho.ClientConfig{
+		ServerUrls:                    c.mqttBrokerUrls,
+		ConnectUsername:               c.mqttUsername,
+		ConnectPassword:               []byte(c.mqttPassword),
+		KeepAlive:                     c.mqttKeepAliveInterval,
+		ConnectRetryDelay:             c.mqttConnectRetryDelay,
+		ConnectTimeout:                c.mqttConnectTimeout,
+		CleanStartOnInitialConnection: !c.mqttPersistentSession,
+		SessionExpiryInterval:         sessionExpiry,
+		WillMessage: &paho.WillMessage{
+			Topic:   c.AvailabilityTopic(),
+			Payload: []byte(availabilityOffline),
+			QoS:     1,
+			Retain:  true,
+		},
+		OnConnectionUp: c.onConnectionUp,
+		ClientConfig: paho.ClientConfig{
+			ClientID: c.mqttClientID,
+			Router:   c.router,
+		},
+	})
+	if err != nil {
+		c.Unlock()
+		return nil, err
+	}
+	c.conn = conn
+	c.Unlock()
+
+	// Don't hold the lock while waiting, onConnectionUp needs it
+	return conn, conn.AwaitConnection(ctx)
+}
+
+func (c *Client) onConnectionUp(manager *autopaho.ConnectionManager, connack *paho.Connack) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.mqttConnectTimeout)
+	defer cancel()
+
+	_, err := manager.Publish(ctx, &paho.Publish{
+		Topic:   c.AvailabilityTopic(),
+		Payload: []byte(availabilityOnline),
+		QoS:     1,
+		Retain:  true,
+	})
+	if err != nil {
+		slog.Error("failed to publish availability", "topic", c.AvailabilityTopic(), "error", err)
+	}
+
+	// A resumed persistent session still has the subscriptions
+	if connack.SessionPresent {
+		return
+	}
+
+	c.Lock()
+	var subscriptions []paho.SubscribeOptions
+	for topic := range c.subscriptions {
+		subscriptions = append(subscriptions, paho.SubscribeOptions{Topic: topic, QoS: c.subscriptionQoS()})
+	}
+	c.Unlock()
+
+	if len(subscriptions) == 0 {
+		return
+	}
+	_, err = manager.Subscribe(ctx, &paho.Subscribe{Subscriptions: subscriptions})
+	if err != nil {
+		slog.Error("failed to resubscribe to topics", "error", err)
+	}
+}
+
+// Subscribe registers handler for topic. The subscription is renewed
+// whenever the connection is reestablished.
+func (c *Client) Subscribe(ctx context.Context, topic string, handler paho.MessageHandler) error {
+	c.Lock()
+	c.subscriptions[topic] = handler
+	c.router.UnregisterHandler(topic)
+	c.router.RegisterHandler(topic, handler)
+	conn := c.conn
+	c.Unlock()
+
+	if conn == nil {
+		// subscribed by onConnectionUp
+		return nil
+	}
+	_, err := conn.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: topic, QoS: c.subscriptionQoS()}},
+	})
+	return err
+}
+
+// Unsubscribe removes the subscription for topic.
+func (c *Client) Unsubscribe(ctx context.Context, topic string) error {
+	c.Lock()
+	delete(c.subscriptions, topic)
+	c.router.UnregisterHandler(topic)
+	conn := c.conn
+	c.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	_, err := conn.Unsubscribe(ctx, &paho.Unsubscribe{Topics: []string{topic}})
+	return err
+}
+
+// Publish publishes p, connecting first if necessary.
+func (c *Client) Publish(ctx context.Context, p *paho.Publish) error {
+	conn, err := c.ensureConnection(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to MQTT: %v", err)
+	}
+
+	_, err = conn.Publish(ctx, p)
+	return err
+}
+
+// Disconnect publishes offline availability, since the broker only sends the
+// Will on connection loss, and closes the connection. Publishing afterwards
+// fails instead of connecting again.
+func (c *Client) Disconnect(ctx context.Context) error {
+	c.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.closed = true
+	c.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	_, err := conn.Publish(ctx, &paho.Publish{
+		Topic:   c.AvailabilityTopic(),
+		Payload: []byte(availabilityOffline),
+		QoS:     1,
+		Retain:  true,
+	})
+	if err != nil {
+		slog.Error("failed to publish availability", "topic", c.AvailabilityTopic(), "error", err)
+	}
+
+	return conn.Disconnect(ctx)
+}
+
+// subscriptionQoS is the QoS used for subscriptions. With a persistent
+// session QoS 1 is required for the broker to queue commands while we are
+// disconnected.
+func (c *Client) subscriptionQoS() byte {
+	if c.mqttPersistentSession {
+		return 1
+	}
+	return 0
+}
+
+// stableSuffix derives a client ID suffix from the hostname, so that the
+// bridge reconnects with the same client ID after a restart.
+func stableSuffix() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "default"
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(hostname))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
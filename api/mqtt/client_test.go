@@ -0,0 +1,133 @@
+package mqtt_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/api/mqtt"
+	"github.com/eclipse/paho.golang/paho"
+	server "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIsSharedByEmitterAndListener(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// start the broker
+	broker, clientUrl := mqtt.NewBroker(t)
+	defer func() {
+		err := broker.Close()
+		assert.NoError(t, err)
+	}()
+	err := broker.Serve()
+	require.NoError(t, err)
+
+	connectedCh := make(chan string, 4)
+	err = broker.AddHook(&connectHook{connectedCh: connectedCh}, nil)
+	require.NoError(t, err)
+
+	client := mqtt.NewClient(
+		mqtt.WithMqttBrokerUrl[mqtt.Client](clientUrl),
+		mqtt.WithMqttClientID[mqtt.Client]("ezr2mqtt-test"),
+	)
+	defer func() {
+		err := client.Disconnect(ctx)
+		assert.NoError(t, err)
+	}()
+
+	receivedMsgCh := make(chan struct{})
+	handler := func(ctx context.Context, name string, msg *api.Message) {
+		receivedMsgCh <- struct{}{}
+	}
+
+	listener := mqtt.NewListener(mqtt.WithClient[mqtt.Listener](client))
+	conn, err := listener.Connect(ctx, api.MessageHandlerFunc(handler))
+	require.NoError(t, err)
+
+	emitter := mqtt.NewEmitter(mqtt.WithClient[mqtt.Emitter](client), mqtt.WithMqttPrefix[mqtt.Emitter]("ezr"))
+	err = emitter.Emit(ctx, "name123", &api.Message{Room: 1, Type: "temperature", Data: "23.20"})
+	require.NoError(t, err)
+
+	publishMessage(t, broker, api.FormatFloat(23.2))
+
+	select {
+	case <-ctx.Done():
+		assert.Fail(t, "timeout waiting for message")
+	case <-receivedMsgCh:
+	}
+
+	// only a single session was opened
+	assert.Equal(t, "ezr2mqtt-test", <-connectedCh)
+	assert.Len(t, connectedCh, 0)
+
+	// the listener doesn't close the shared connection
+	err = conn.Disconnect(ctx)
+	require.NoError(t, err)
+	err = emitter.Emit(ctx, "name123", &api.Message{Room: 1, Type: "temperature", Data: "23.20"})
+	assert.NoError(t, err)
+}
+
+func TestClientPublishesAvailability(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	broker, clientUrl := mqtt.NewBroker(t)
+	defer func() {
+		err := broker.Close()
+		assert.NoError(t, err)
+	}()
+	err := broker.Serve()
+	require.NoError(t, err)
+
+	client := mqtt.NewClient(mqtt.WithMqttBrokerUrl[mqtt.Client](clientUrl))
+	assert.Equal(t, "ezr/availability", client.AvailabilityTopic())
+
+	err = client.Connect(ctx)
+	require.NoError(t, err)
+
+	// the availability is published retained
+	assert.Eventually(t, func() bool {
+		retained := broker.Topics.Messages("ezr/availability")
+		return len(retained) == 1 && string(retained[0].Payload) == "online"
+	}, time.Second, 10*time.Millisecond)
+
+	// a clean disconnect doesn't trigger the Will
+	err = client.Disconnect(ctx)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		retained := broker.Topics.Messages("ezr/availability")
+		return len(retained) == 1 && string(retained[0].Payload) == "offline"
+	}, time.Second, 10*time.Millisecond)
+
+	// publishing afterwards fails instead of connecting and going online again
+	err = client.Publish(ctx, &paho.Publish{Topic: "ezr/name123/1/state/temperature", Payload: []byte("23.20")})
+	assert.Error(t, err)
+	time.Sleep(100 * time.Millisecond)
+	retained := broker.Topics.Messages("ezr/availability")
+	require.Len(t, retained, 1)
+	assert.Equal(t, "offline", string(retained[0].Payload))
+}
+
+type connectHook struct {
+	server.HookBase
+	connectedCh chan string
+}
+
+func (h *connectHook) ID() string {
+	return "connect-hook"
+}
+
+func (h *connectHook) Provides(b byte) bool {
+	return b == server.OnConnect
+}
+
+func (h *connectHook) OnConnect(cl *server.Client, pk packets.Packet) error {
+	h.connectedCh <- cl.ID
+	return nil
+}
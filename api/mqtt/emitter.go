@@ -5,18 +5,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/chrishrb/ezr2mqtt/api"
-	"github.com/eclipse/paho.golang/autopaho"
 	"github.com/eclipse/paho.golang/paho"
 )
 
+// RoomTopics selects how rooms are addressed in state topics.
+type RoomTopics string
+
+const (
+	// RoomTopicsNumber uses the number of the heat area, e.g. ezr/eg/1/state/temperature_actual
+	RoomTopicsNumber RoomTopics = "number"
+	// RoomTopicsName uses the slug of the room's name, e.g. ezr/eg/living_room/state/temperature_actual
+	RoomTopicsName RoomTopics = "name"
+)
+
 type Emitter struct {
-	sync.Mutex
 	connectionDetails
-	conn *autopaho.ConnectionManager
+	mqttRoomTopics RoomTopics
+	client         *Client
 }
 
 func NewEmitter(opts ...Opt[Emitter]) *Emitter {
@@ -29,15 +39,9 @@ func NewEmitter(opts ...Opt[Emitter]) *Emitter {
 }
 
 func (e *Emitter) Emit(ctx context.Context, name string, message *api.Message) error {
-	// e.g. ezr/your-name/bedroom/state/temperature
-	t := fmt.Sprintf("%s/%s/%d/state/%s", e.mqttPrefix, name, message.Room, message.Type)
-
-	err := e.ensureConnection(ctx)
-	if err != nil {
-		return fmt.Errorf("connecting to MQTT: %v", err)
-	}
+	t := e.StateTopic(name, message)
 
-	_, err = e.conn.Publish(ctx, &paho.Publish{
+	err := e.client.Publish(ctx, &paho.Publish{
 		Topic:   t,
 		Payload: []byte(message.Data),
 	})
@@ -47,13 +51,28 @@ func (e *Emitter) Emit(ctx context.Context, name string, message *api.Message) e
 	return nil
 }
 
+func (e *Emitter) StateTopic(name string, message *api.Message) string {
+	// e.g. ezr/your-name/1/state/temperature
+	return fmt.Sprintf("%s/%s/%s/state/%s", e.mqttPrefix, name, e.roomSegment(message), message.Type)
+}
+
+func (e *Emitter) CommandTopic(name string, message *api.Message) string {
+	// e.g. ezr/your-name/1/set/temperature
+	return fmt.Sprintf("%s/%s/%s/set/%s", e.mqttPrefix, name, e.roomSegment(message), message.Type)
+}
+
+func (e *Emitter) roomSegment(message *api.Message) string {
+	if e.mqttRoomTopics == RoomTopicsName && message.RoomName != "" {
+		return message.RoomName
+	}
+	return strconv.Itoa(message.Room)
+}
+
 func (e *Emitter) EmitHADiscovery(ctx context.Context, component api.HAComponent, message api.HASensorDiscovery) error {
-	// e.g. homeassistant/<component>/<unique_id>/config
-	t := fmt.Sprintf("%s/%s/%s/config", e.mqttHADiscoveryPrefix, component, message.UniqueID)
+	t := e.HADiscoveryTopic(component, message.UniqueID)
 
-	err := e.ensureConnection(ctx)
-	if err != nil {
-		return fmt.Errorf("connecting to MQTT: %v", err)
+	if message.AvailabilityTopic == "" {
+		message.AvailabilityTopic = e.client.AvailabilityTopic()
 	}
 
 	msg, err := json.Marshal(message)
@@ -61,7 +80,7 @@ func (e *Emitter) EmitHADiscovery(ctx context.Context, component api.HAComponent
 		return fmt.Errorf("marshalling HA discovery message: %v", err)
 	}
 
-	_, err = e.conn.Publish(ctx, &paho.Publish{
+	err = e.client.Publish(ctx, &paho.Publish{
 		Topic:   t,
 		Payload: msg,
 	})
@@ -71,6 +90,36 @@ func (e *Emitter) EmitHADiscovery(ctx context.Context, component api.HAComponent
 	return nil
 }
 
+func (e *Emitter) RemoveHADiscovery(ctx context.Context, component api.HAComponent, uniqueID string) error {
+	t := e.HADiscoveryTopic(component, uniqueID)
+
+	// an empty config removes the entity, retained to clear any retained config
+	err := e.client.Publish(ctx, &paho.Publish{
+		Topic:  t,
+		Retain: true,
+	})
+	if err != nil {
+		return fmt.Errorf("publishing to %s: %v", t, err)
+	}
+	return nil
+}
+
+func (e *Emitter) HADiscoveryTopic(component api.HAComponent, uniqueID string) string {
+	// e.g. homeassistant/<component>/<unique_id>/config
+	return fmt.Sprintf("%s/%s/%s/config", e.mqttHADiscoveryPrefix, component, objectID(uniqueID))
+}
+
+// objectID replaces the characters Home Assistant does not accept in the
+// object ID of a discovery topic, e.g. the spaces of a device name.
+func objectID(uniqueID string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, uniqueID)
+}
+
 func ensureEmitterDefaults(e *Emitter) {
 	if e.mqttBrokerUrls == nil {
 		u, err := url.Parse("mqtt://127.0.0.1:1883/")
@@ -94,31 +143,17 @@ func ensureEmitterDefaults(e *Emitter) {
 	if e.mqttHADiscoveryPrefix == "" {
 		e.mqttHADiscoveryPrefix = "homeassistant"
 	}
-}
-
-func (e *Emitter) ensureConnection(ctx context.Context) error {
-	e.Lock()
-	defer e.Unlock()
-	if e.conn == nil {
-		conn, err := autopaho.NewConnection(context.Background(), autopaho.ClientConfig{
-			ServerUrls:        e.mqttBrokerUrls,
-			ConnectUsername:   e.mqttUsername,
-			ConnectPassword:   []byte(e.mqttPassword),
-			KeepAlive:         e.mqttKeepAliveInterval,
-			ConnectRetryDelay: e.mqttConnectRetryDelay,
-			ClientConfig: paho.ClientConfig{
-				ClientID: fmt.Sprintf("%s-%s", "ezr2mqtt-emit", randSeq(5)),
-			},
-		})
-		if err != nil {
-			return err
-		}
-		e.conn = conn
-
-		err = conn.AwaitConnection(ctx)
-		if err != nil {
-			return err
-		}
+	if e.mqttRoomTopics == "" {
+		e.mqttRoomTopics = RoomTopicsNumber
+	}
+	if e.client == nil {
+		e.client = NewClient(
+			WithMqttBrokerUrls[Client](e.mqttBrokerUrls),
+			WithMqttPrefix[Client](e.mqttPrefix),
+			WithMqttConnectSettings[Client](e.mqttConnectTimeout, e.mqttConnectRetryDelay, time.Duration(e.mqttKeepAliveInterval)*time.Second),
+			WithMqttUsername[Client](e.mqttUsername),
+			WithMqttPassword[Client](e.mqttPassword),
+			WithMqttClientID[Client](fmt.Sprintf("%s-%s", "ezr2mqtt-emit", randSeq(5))),
+		)
 	}
-	return nil
 }
@@ -66,6 +66,45 @@ func TestEmitterSendsMessage(t *testing.T) {
 	}
 }
 
+func TestEmitterTopics(t *testing.T) {
+	msg := &api.Message{Room: 2, RoomName: "bedroom", Type: "temperature_target"}
+
+	tests := []struct {
+		name       string
+		roomTopics mqtt2.RoomTopics
+		msg        *api.Message
+		state      string
+		command    string
+	}{
+		{"number", mqtt2.RoomTopicsNumber, msg, "ezr/eg/2/state/temperature_target", "ezr/eg/2/set/temperature_target"},
+		{"name", mqtt2.RoomTopicsName, msg, "ezr/eg/bedroom/state/temperature_target", "ezr/eg/bedroom/set/temperature_target"},
+		{"name without room name", mqtt2.RoomTopicsName, &api.Message{Room: 2, Type: "temperature_target"}, "ezr/eg/2/state/temperature_target", "ezr/eg/2/set/temperature_target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emitter := mqtt2.NewEmitter(
+				mqtt2.WithMqttPrefix[mqtt2.Emitter]("ezr"),
+				mqtt2.WithMqttRoomTopics[mqtt2.Emitter](tt.roomTopics))
+
+			assert.Equal(t, tt.state, emitter.StateTopic("eg", tt.msg))
+			assert.Equal(t, tt.command, emitter.CommandTopic("eg", tt.msg))
+		})
+	}
+}
+
+func TestEmitterHADiscoveryTopic(t *testing.T) {
+	emitter := mqtt2.NewEmitter()
+
+	assert.Equal(t, "homeassistant/number/device1-1-temperature_target/config",
+		emitter.HADiscoveryTopic(api.HAComponentNumber, "device1-1-temperature_target"))
+	// characters outside of [a-zA-Z0-9_-] are rejected by Home Assistant
+	assert.Equal(t, "homeassistant/sensor/ezr_og-1-temperature/config",
+		emitter.HADiscoveryTopic(api.HAComponentSensor, "ezr og-1-temperature"))
+	assert.Equal(t, "homeassistant/sensor/w_rme_1_2-firmware/config",
+		emitter.HADiscoveryTopic(api.HAComponentSensor, "wärme/1.2-firmware"))
+}
+
 func listenForMessageSentByManager(t *testing.T, ctx context.Context, clientUrl *url.URL, router paho.Router) *autopaho.ConnectionManager {
 	mqttClient, err := autopaho.NewConnection(context.Background(), autopaho.ClientConfig{
 		BrokerUrls:        []*url.URL{clientUrl},
@@ -2,7 +2,6 @@ package mqtt
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
@@ -12,13 +11,13 @@ import (
 	"time"
 
 	"github.com/chrishrb/ezr2mqtt/api"
-	"github.com/eclipse/paho.golang/autopaho"
 	"github.com/eclipse/paho.golang/paho"
 )
 
 type Listener struct {
 	connectionDetails
 	mqttGroup string
+	client    *Client
 }
 
 func NewListener(opts ...Opt[Listener]) *Listener {
@@ -55,89 +54,115 @@ func ensureListenerDefaults(l *Listener) {
 	}
 }
 
+// Connect subscribes handler to the command topics and connects to the
+// broker. The handler is registered before the connection is up, so that
+// commands queued by the broker for a persistent session are not lost.
 func (l *Listener) Connect(ctx context.Context, handler api.MessageHandler) (api.Connection, error) {
-	var err error
+	conn := &connection{client: l.client}
+	if conn.client == nil {
+		// without a shared client the connection is ours to close
+		conn.owned = true
+		conn.client = NewClient(
+			WithMqttBrokerUrls[Client](l.mqttBrokerUrls),
+			WithMqttPrefix[Client](l.mqttPrefix),
+			WithMqttConnectSettings[Client](l.mqttConnectTimeout, l.mqttConnectRetryDelay, time.Duration(l.mqttKeepAliveInterval)*time.Second),
+			WithMqttUsername[Client](l.mqttUsername),
+			WithMqttPassword[Client](l.mqttPassword),
+			WithMqttClientID[Client](fmt.Sprintf("%s-%s", l.mqttGroup, randSeq(5))),
+		)
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, l.mqttConnectTimeout)
+	// the connection is made by the client, with its settings
+	ctx, cancel := context.WithTimeout(ctx, conn.client.mqttConnectTimeout)
 	defer cancel()
 
-	clientId := fmt.Sprintf("%s-%s", l.mqttGroup, randSeq(5))
+	// e.g. ezr/name123/bedroom/set/temperature
+	conn.topic = fmt.Sprintf("%s/+/+/set/+", l.mqttPrefix)
 
-	readyCh := make(chan struct{})
+	handle := func(mqttMsg *paho.Publish) {
+		ctx := context.Background()
 
-	// e.g. ezr/name123/bedroom/set/temperature
-	topic := fmt.Sprintf("%s/+/+/set/+", l.mqttPrefix)
-
-	conn := new(connection)
-	mqttRouter := paho.NewStandardRouter()
-	conn.mqttConn, err = autopaho.NewConnection(context.Background(), autopaho.ClientConfig{
-		ServerUrls:        l.mqttBrokerUrls,
-		ConnectUsername:   l.mqttUsername,
-		ConnectPassword:   []byte(l.mqttPassword),
-		KeepAlive:         l.mqttKeepAliveInterval,
-		ConnectRetryDelay: l.mqttConnectRetryDelay,
-		OnConnectionUp: func(manager *autopaho.ConnectionManager, connack *paho.Connack) {
-			_, err := manager.Subscribe(ctx, &paho.Subscribe{
-				Subscriptions: []paho.SubscribeOptions{{Topic: topic}},
-			})
-			if err != nil {
-				slog.Error("failed to subscribe to topic", "topic", topic)
-				return
-			}
-			mqttRouter.UnregisterHandler(topic)
-			mqttRouter.RegisterHandler(topic, func(mqttMsg *paho.Publish) {
-				ctx := context.Background()
-
-				// determine parts - ezr/name123/bedroom/set/temperature
-				topicParts := strings.Split(mqttMsg.Topic, "/")
-
-				name := topicParts[len(topicParts)-4]
-				t := topicParts[len(topicParts)-1]
-				room, err := strconv.Atoi(topicParts[len(topicParts)-3])
-				if err != nil {
-					slog.Error("invalid room number in topic", "topic", mqttMsg.Topic)
-				}
-
-				msg := api.Message{
-					Room: room,
-					Type: t,
-					Data: string(mqttMsg.Payload),
-				}
-
-				// execute the handler
-				handler.Handle(ctx, name, &msg)
-			})
-			readyCh <- struct{}{}
-		},
-		ClientConfig: paho.ClientConfig{
-			ClientID: clientId,
-			Router:   mqttRouter,
-		},
-	})
+		// determine parts - ezr/name123/bedroom/set/temperature
+		topicParts := strings.Split(mqttMsg.Topic, "/")
+
+		name := topicParts[len(topicParts)-4]
+		t := topicParts[len(topicParts)-1]
+		room, roomName, err := parseRoom(topicParts[len(topicParts)-3])
+		if err != nil {
+			slog.Error("invalid room in topic, ignoring message", "topic", mqttMsg.Topic, "error", err)
+			return
+		}
+
+		msg := api.Message{
+			Room:     room,
+			RoomName: roomName,
+			Type:     t,
+			Data:     string(mqttMsg.Payload),
+		}
+
+		// execute the handler
+		handler.Handle(ctx, name, &msg)
+	}
+
+	// registered before connecting, so that the router handles messages
+	// delivered right after the connection is up
+	err := conn.client.Subscribe(ctx, conn.topic, handle)
 	if err != nil {
+		slog.Error("failed to subscribe to topic", "topic", conn.topic)
 		return nil, err
 	}
 
-	select {
-	case <-ctx.Done():
-		return nil, errors.New("timeout waiting for mqtt connectionDetails setup")
-	case <-readyCh:
-		return conn, nil
+	err = conn.client.Connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for mqtt connection: %w", err)
 	}
+
+	// a new connection subscribes in the background, subscribe again to
+	// return only once the broker acknowledged the subscription
+	err = conn.client.Subscribe(ctx, conn.topic, handle)
+	if err != nil {
+		slog.Error("failed to subscribe to topic", "topic", conn.topic)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// parseRoom parses the room segment of a topic, which is either the number
+// of the heat area or the slug of its name (see api.Slug).
+func parseRoom(segment string) (room int, roomName string, err error) {
+	if room, err := strconv.Atoi(segment); err == nil {
+		if room < 0 {
+			return 0, "", fmt.Errorf("negative room number: %d", room)
+		}
+		return room, "", nil
+	}
+	if segment == "" || api.Slug(segment) != segment {
+		return 0, "", fmt.Errorf("not a room number or room name: %q", segment)
+	}
+	return 0, segment, nil
 }
 
 type connection struct {
-	mqttConn *autopaho.ConnectionManager
+	client *Client
+	topic  string
+	owned  bool
 }
 
 func (c *connection) Disconnect(ctx context.Context) error {
-	if c.mqttConn != nil {
-		err := c.mqttConn.Disconnect(ctx)
-		if err != nil {
-			return err
-		}
-		c.mqttConn = nil
+	if c.client == nil {
+		return nil
+	}
+	var err error
+	if c.owned {
+		err = c.client.Disconnect(ctx)
+	} else {
+		err = c.client.Unsubscribe(ctx, c.topic)
+	}
+	if err != nil {
+		return err
 	}
+	c.client = nil
 	return nil
 }
 
@@ -2,6 +2,7 @@ package mqtt_test
 
 import (
 	"context"
+	"net/url"
 	"testing"
 	"time"
 
@@ -59,7 +60,138 @@ func TestListenerProcessesMessagesReceivedFromTheBroker(t *testing.T) {
 	}
 }
 
+func TestListenerPassesRoomNamesAndDropsInvalidRooms(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// start the broker
+	broker, clientUrl := mqtt.NewBroker(t)
+	defer func() {
+		err := broker.Close()
+		assert.NoError(t, err)
+	}()
+	err := broker.Serve()
+	require.NoError(t, err)
+
+	// setup the handler
+	receivedMsgCh := make(chan *api.Message, 2)
+	receivedNames := make(chan string, 2)
+	handler := func(ctx context.Context, name string, msg *api.Message) {
+		receivedNames <- name
+		receivedMsgCh <- msg
+	}
+
+	listener := mqtt.NewListener(mqtt.WithMqttBrokerUrl[mqtt.Listener](clientUrl))
+	conn, err := listener.Connect(ctx, api.MessageHandlerFunc(handler))
+	require.NoError(t, err)
+	defer func() {
+		err := conn.Disconnect(ctx)
+		require.NoError(t, err)
+	}()
+
+	// the invalid room is dropped, so the named room arrives first
+	publishMessageTo(t, broker, "ezr/name123/Living Room!/set/temperature", "21")
+	publishMessageTo(t, broker, "ezr/name123/-1/set/temperature", "21")
+	publishMessageTo(t, broker, "ezr/name123/living_room/set/temperature", "22")
+
+	select {
+	case <-ctx.Done():
+		assert.Fail(t, "timeout waiting for test to complete")
+	case msg := <-receivedMsgCh:
+		assert.Equal(t, 0, msg.Room)
+		assert.Equal(t, "living_room", msg.RoomName)
+		assert.Equal(t, "22", msg.Data)
+		assert.Equal(t, "name123", <-receivedNames)
+	}
+
+	// broadcasts are passed on as they are
+	publishMessageTo(t, broker, "ezr/all/all/set/heatarea_mode", "night")
+
+	select {
+	case <-ctx.Done():
+		assert.Fail(t, "timeout waiting for test to complete")
+	case msg := <-receivedMsgCh:
+		assert.Equal(t, api.All, msg.RoomName)
+		assert.Equal(t, api.All, <-receivedNames)
+		assert.Equal(t, "night", msg.Data)
+	}
+}
+
+func TestListenerReceivesCommandsQueuedForPersistentSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// start the broker
+	broker, clientUrl := mqtt.NewBroker(t)
+	defer func() {
+		err := broker.Close()
+		assert.NoError(t, err)
+	}()
+	err := broker.Serve()
+	require.NoError(t, err)
+
+	newClient := func() *mqtt.Client {
+		return mqtt.NewClient(
+			mqtt.WithMqttBrokerUrl[mqtt.Client](clientUrl),
+			mqtt.WithMqttClientID[mqtt.Client]("ezr2mqtt-persistent"),
+			mqtt.WithMqttPersistentSession[mqtt.Client](time.Hour),
+		)
+	}
+	receivedMsgCh := make(chan *api.Message, 1)
+	handler := api.MessageHandlerFunc(func(ctx context.Context, name string, msg *api.Message) {
+		receivedMsgCh <- msg
+	})
+
+	// create the session and go offline
+	client := newClient()
+	_, err = mqtt.NewListener(mqtt.WithClient[mqtt.Listener](client)).Connect(ctx, handler)
+	require.NoError(t, err)
+	require.NoError(t, client.Disconnect(ctx))
+
+	// queued by the broker while offline
+	require.NoError(t, broker.Publish("ezr/name123/1/set/temperature", []byte("21"), false, 1))
+
+	// delivered as soon as the session is resumed
+	client = newClient()
+	defer func() {
+		err := client.Disconnect(ctx)
+		assert.NoError(t, err)
+	}()
+	_, err = mqtt.NewListener(mqtt.WithClient[mqtt.Listener](client)).Connect(ctx, handler)
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+		assert.Fail(t, "timeout waiting for the queued command")
+	case msg := <-receivedMsgCh:
+		assert.Equal(t, 1, msg.Room)
+		assert.Equal(t, "21", msg.Data)
+	}
+}
+
+func TestListenerUsesConnectSettingsOfSharedClient(t *testing.T) {
+	// nothing listens on the port
+	clientUrl, err := url.Parse("ws://127.0.0.1:1")
+	require.NoError(t, err)
+
+	client := mqtt.NewClient(
+		mqtt.WithMqttBrokerUrl[mqtt.Client](clientUrl),
+		mqtt.WithMqttConnectSettings[mqtt.Client](200*time.Millisecond, 50*time.Millisecond, 10*time.Second),
+	)
+
+	// no broker is listening, the connect timeout of the client applies
+	start := time.Now()
+	_, err = mqtt.NewListener(mqtt.WithClient[mqtt.Listener](client)).Connect(context.Background(), api.MessageHandlerFunc(
+		func(ctx context.Context, name string, msg *api.Message) {}))
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
 func publishMessage(t *testing.T, broker *server.Server, msg string) {
+	publishMessageTo(t, broker, "ezr/name123/1/set/temperature", msg)
+}
+
+func publishMessageTo(t *testing.T, broker *server.Server, topic string, msg string) {
 	cl := broker.NewClient(nil, "local", "inline", true)
 	err := broker.InjectPacket(cl, packets.Packet{
 		FixedHeader: packets.FixedHeader{
@@ -67,7 +199,7 @@ func publishMessage(t *testing.T, broker *server.Server, msg string) {
 			Qos:    0,
 			Retain: false,
 		},
-		TopicName: "ezr/name123/1/set/temperature",
+		TopicName: topic,
 		Payload:   []byte(msg),
 		PacketID:  uint16(0),
 	})
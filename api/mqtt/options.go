@@ -18,35 +18,41 @@ type connectionDetails struct {
 
 type Opt[T any] func(h *T)
 
-func WithMqttBrokerUrl[T Emitter | Listener](brokerUrl *url.URL) Opt[T] {
+func WithMqttBrokerUrl[T Emitter | Listener | Client](brokerUrl *url.URL) Opt[T] {
 	return func(h *T) {
 		switch x := any(h).(type) {
 		case *Emitter:
 			x.mqttBrokerUrls = append(x.mqttBrokerUrls, brokerUrl)
 		case *Listener:
 			x.mqttBrokerUrls = append(x.mqttBrokerUrls, brokerUrl)
+		case *Client:
+			x.mqttBrokerUrls = append(x.mqttBrokerUrls, brokerUrl)
 		}
 	}
 }
 
-func WithMqttBrokerUrls[T Emitter | Listener](brokerUrls []*url.URL) Opt[T] {
+func WithMqttBrokerUrls[T Emitter | Listener | Client](brokerUrls []*url.URL) Opt[T] {
 	return func(h *T) {
 		switch x := any(h).(type) {
 		case *Emitter:
 			x.mqttBrokerUrls = brokerUrls
 		case *Listener:
 			x.mqttBrokerUrls = brokerUrls
+		case *Client:
+			x.mqttBrokerUrls = brokerUrls
 		}
 	}
 }
 
-func WithMqttPrefix[T Emitter | Listener](mqttPrefix string) Opt[T] {
+func WithMqttPrefix[T Emitter | Listener | Client](mqttPrefix string) Opt[T] {
 	return func(h *T) {
 		switch x := any(h).(type) {
 		case *Emitter:
 			x.mqttPrefix = mqttPrefix
 		case *Listener:
 			x.mqttPrefix = mqttPrefix
+		case *Client:
+			x.mqttPrefix = mqttPrefix
 		}
 	}
 }
@@ -60,7 +66,16 @@ func WithMqttHADiscoveryPrefix[T Emitter](mqttHADiscoveryPrefix string) Opt[T] {
 	}
 }
 
-func WithMqttConnectSettings[T Emitter | Listener](mqttConnectTimeout, mqttConnectRetryDelay, mqttKeepAliveInterval time.Duration) Opt[T] {
+func WithMqttRoomTopics[T Emitter](mqttRoomTopics RoomTopics) Opt[T] {
+	return func(h *T) {
+		switch x := any(h).(type) {
+		case *Emitter:
+			x.mqttRoomTopics = mqttRoomTopics
+		}
+	}
+}
+
+func WithMqttConnectSettings[T Emitter | Listener | Client](mqttConnectTimeout, mqttConnectRetryDelay, mqttKeepAliveInterval time.Duration) Opt[T] {
 	return func(h *T) {
 		switch x := any(h).(type) {
 		case *Emitter:
@@ -71,37 +86,83 @@ func WithMqttConnectSettings[T Emitter | Listener](mqttConnectTimeout, mqttConne
 			x.mqttConnectTimeout = mqttConnectTimeout
 			x.mqttConnectRetryDelay = mqttConnectRetryDelay
 			x.mqttKeepAliveInterval = uint16(mqttKeepAliveInterval.Round(time.Second).Seconds())
+		case *Client:
+			x.mqttConnectTimeout = mqttConnectTimeout
+			x.mqttConnectRetryDelay = mqttConnectRetryDelay
+			x.mqttKeepAliveInterval = uint16(mqttKeepAliveInterval.Round(time.Second).Seconds())
 		}
 	}
 }
 
-func WithMqttUsername[T Emitter | Listener](mqttUsername string) Opt[T] {
+func WithMqttUsername[T Emitter | Listener | Client](mqttUsername string) Opt[T] {
 	return func(h *T) {
 		switch x := any(h).(type) {
 		case *Emitter:
 			x.mqttUsername = mqttUsername
 		case *Listener:
 			x.mqttUsername = mqttUsername
+		case *Client:
+			x.mqttUsername = mqttUsername
 		}
 	}
 }
 
-func WithMqttPassword[T Emitter | Listener](mqttPassword string) Opt[T] {
+func WithMqttPassword[T Emitter | Listener | Client](mqttPassword string) Opt[T] {
 	return func(h *T) {
 		switch x := any(h).(type) {
 		case *Emitter:
 			x.mqttPassword = mqttPassword
 		case *Listener:
 			x.mqttPassword = mqttPassword
+		case *Client:
+			x.mqttPassword = mqttPassword
 		}
 	}
 }
 
-func WithMqttGroup[T Listener](mqttGroup string) Opt[T] {
+func WithMqttGroup[T Listener | Client](mqttGroup string) Opt[T] {
 	return func(h *T) {
 		switch x := any(h).(type) {
 		case *Listener:
 			x.mqttGroup = mqttGroup
+		case *Client:
+			x.mqttGroup = mqttGroup
+		}
+	}
+}
+
+func WithMqttClientID[T Client](mqttClientID string) Opt[T] {
+	return func(h *T) {
+		switch x := any(h).(type) {
+		case *Client:
+			x.mqttClientID = mqttClientID
+		}
+	}
+}
+
+// WithMqttPersistentSession keeps the session on the broker for
+// sessionExpiry after the connection is lost, so that commands sent in the
+// meantime are delivered once we are back.
+func WithMqttPersistentSession[T Client](sessionExpiry time.Duration) Opt[T] {
+	return func(h *T) {
+		switch x := any(h).(type) {
+		case *Client:
+			x.mqttPersistentSession = true
+			x.mqttSessionExpiry = sessionExpiry
+		}
+	}
+}
+
+// WithClient makes the Emitter or Listener use a shared connection instead
+// of opening its own. The connection settings of the Emitter or Listener
+// are ignored in that case.
+func WithClient[T Emitter | Listener](client *Client) Opt[T] {
+	return func(h *T) {
+		switch x := any(h).(type) {
+		case *Emitter:
+			x.client = client
+		case *Listener:
+			x.client = client
 		}
 	}
 }
@@ -0,0 +1,13 @@
+package api
+
+// Slot is a switching time of a program in which the day temperature
+// applies, e.g. {"start":"06:00","end":"22:00"}.
+type Slot struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Programs is the JSON payload of the programs state and command topics. It
+// maps the number of a program to its slots, e.g. {"1":[{"start":"06:00","end":"22:00"}]}.
+// Programs missing in a command are left unchanged.
+type Programs map[int][]Slot
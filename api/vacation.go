@@ -0,0 +1,15 @@
+package api
+
+// VacationTimeLayout is the layout of the start and end of a vacation, e.g.
+// 2025-12-24T18:00.
+const VacationTimeLayout = "2006-01-02T15:04"
+
+// Vacation is the JSON payload of the vacation state and command topics,
+// e.g. {"enabled":true,"start":"2025-12-24T18:00","end":"2026-01-06T12:00","temperature":16}.
+// Fields missing in a command keep their current value.
+type Vacation struct {
+	Enabled     *bool    `json:"enabled,omitempty"`
+	Start       *string  `json:"start,omitempty"`
+	End         *string  `json:"end,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
@@ -3,11 +3,18 @@ package cmd
 import (
 	"context"
 	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/chrishrb/ezr2mqtt/config"
 	"github.com/spf13/cobra"
 )
 
+// disconnectTimeout bounds the time to say goodbye to the broker
+const disconnectTimeout = 5 * time.Second
+
 var (
 	configFile string
 )
@@ -24,40 +31,78 @@ var startCmd = &cobra.Command{
 			}
 		}
 
-		settings, err := config.Configure(context.Background(), &cfg)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		settings, err := config.Configure(ctx, &cfg)
 		if err != nil {
 			return err
 		}
 
-		errCh := make(chan error, 1)
+		slog.Info("using mqtt broker", "mqtt", cfg.Api.Mqtt)
 
 		// Connect to mqtt broker and start listening for messages
-		conn, err := settings.MqttListener.Connect(context.Background(), settings.MqttHandler)
+		conn, err := settings.MqttListener.Connect(ctx, settings.MqttHandler)
 		if err != nil {
-			errCh <- err
+			disconnect(settings)
+			return err
 		}
 
 		// Start periodic requests
 		periodicRequester := settings.PeriodicRequester
 		for _, pr := range periodicRequester {
-			pr.Run(context.Background())
+			pr.Run(ctx)
 		}
 
 		slog.Info("ezr2mqtt started")
 
-		err = <-errCh
+		<-ctx.Done()
+		// a second signal terminates right away
+		stop()
+		slog.Info("shutting down ezr2mqtt", "drain_timeout", settings.DrainTimeout)
 
-		if conn != nil {
-			err := conn.Disconnect(context.Background())
-			if err != nil {
-				slog.Warn("closing transport connection", "error", err)
+		// Stop accepting commands
+		disconnectCtx, cancel := context.WithTimeout(context.Background(), disconnectTimeout)
+		err = conn.Disconnect(disconnectCtx)
+		cancel()
+		if err != nil {
+			slog.Warn("closing transport connection", "error", err)
+		}
+
+		// Send pending commands and wait for in-flight requests
+		drained := make(chan struct{})
+		go func() {
+			settings.MqttHandler.Flush()
+			settings.HandlerRouter.Wait()
+			for _, pr := range periodicRequester {
+				pr.Wait()
 			}
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-time.After(settings.DrainTimeout):
+			slog.Warn("timed out waiting for in-flight requests")
 		}
 
-		return err
+		disconnect(settings)
+		slog.Info("ezr2mqtt stopped")
+
+		return nil
 	},
 }
 
+// disconnect closes the mqtt connection, publishing offline availability.
+func disconnect(settings *config.Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), disconnectTimeout)
+	defer cancel()
+
+	if err := settings.MqttConnection.Disconnect(ctx); err != nil {
+		slog.Warn("closing mqtt connection", "error", err)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(startCmd)
 
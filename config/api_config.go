@@ -1,17 +1,54 @@
 package config
 
+import (
+	"log/slog"
+)
+
 type MqttSettingsConfig struct {
-	Urls              []string `mapstructure:"urls" toml:"urls" validate:"required,dive,required"`
-	Username          *string  `mapstructure:"username" toml:"username"`
-	Password          *string  `mapstructure:"password" toml:"password"`
-	Prefix            string   `mapstructure:"prefix" toml:"prefix" validate:"required"`
-	Group             string   `mapstructure:"group" toml:"group" validate:"required"`
-	ConnectTimeout    string   `mapstructure:"connect_timeout" toml:"connect_timeout" validate:"required"`
-	ConnectRetryDelay string   `mapstructure:"connect_retry_delay" toml:"connect_retry_delay" validate:"required"`
-	KeepAliveInterval string   `mapstructure:"keep_alive_interval" toml:"keep_alive_interval" validate:"required"`
+	Urls              []string `mapstructure:"urls" yaml:"urls" toml:"urls" validate:"required,dive,required"`
+	Username          *string  `mapstructure:"username" yaml:"username" toml:"username"`
+	Password          *string  `mapstructure:"password" yaml:"password" toml:"password" validate:"excluded_with=PasswordFile"`
+	PasswordFile      *string  `mapstructure:"password_file" yaml:"password_file" toml:"password_file"`
+	Prefix            string   `mapstructure:"prefix" yaml:"prefix" toml:"prefix" validate:"required"`
+	RoomTopics        string   `mapstructure:"room_topics" yaml:"room_topics" toml:"room_topics" validate:"required,oneof=number name"`
+	Group             string   `mapstructure:"group" yaml:"group" toml:"group" validate:"required"`
+	ConnectTimeout    string   `mapstructure:"connect_timeout" yaml:"connect_timeout" toml:"connect_timeout" validate:"required"`
+	ConnectRetryDelay string   `mapstructure:"connect_retry_delay" yaml:"connect_retry_delay" toml:"connect_retry_delay" validate:"required"`
+	KeepAliveInterval string   `mapstructure:"keep_alive_interval" yaml:"keep_alive_interval" toml:"keep_alive_interval" validate:"required"`
+	ClientID          *string  `mapstructure:"client_id" yaml:"client_id" toml:"client_id"`
+	PersistentSession bool     `mapstructure:"persistent_session" yaml:"persistent_session" toml:"persistent_session"`
+	SessionExpiry     string   `mapstructure:"session_expiry" yaml:"session_expiry" toml:"session_expiry" validate:"required_if=PersistentSession true"`
+}
+
+// LogValue implements slog.LogValuer so that credentials never end up in
+// the logs, neither from the password settings nor from the URL userinfo.
+func (c MqttSettingsConfig) LogValue() slog.Value {
+	urls := make([]string, len(c.Urls))
+	for i, u := range c.Urls {
+		urls[i] = redactURL(u)
+	}
+
+	attrs := []slog.Attr{
+		slog.Any("urls", urls),
+		slog.String("prefix", c.Prefix),
+		slog.String("group", c.Group),
+	}
+	if c.ClientID != nil {
+		attrs = append(attrs, slog.String("client_id", *c.ClientID))
+	}
+	if c.Username != nil {
+		attrs = append(attrs, slog.String("username", *c.Username))
+	}
+	if c.Password != nil {
+		attrs = append(attrs, slog.String("password", redacted))
+	}
+	if c.PasswordFile != nil {
+		attrs = append(attrs, slog.String("password_file", *c.PasswordFile))
+	}
+	return slog.GroupValue(attrs...)
 }
 
 type ApiSettingsConfig struct {
-	Type string              `mapstructure:"type" toml:"type" validate:"required,oneof=mqtt"`
-	Mqtt *MqttSettingsConfig `mapstructure:"mqtt,omitempty" toml:"mqtt,omitempty" validate:"required_if=Type mqtt"`
+	Type string              `mapstructure:"type" yaml:"type" toml:"type" validate:"required,oneof=mqtt"`
+	Mqtt *MqttSettingsConfig `mapstructure:"mqtt,omitempty" yaml:"mqtt,omitempty" toml:"mqtt,omitempty" validate:"required_if=Type mqtt"`
 }
@@ -6,13 +6,13 @@ import (
 	"os"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/stretchr/testify/assert/yaml"
+	"gopkg.in/yaml.v3"
 )
 
 type BaseConfig struct {
-	Api     ApiSettingsConfig `mapstructure:"api" json:"api" validate:"required"`
-	Ezr     []EzrConfig       `mapstructure:"ezr" json:"ezr" validate:"required"`
-	General GeneralConfig     `mapstructure:"general" json:"general" validate:"required"`
+	Api     ApiSettingsConfig `mapstructure:"api" yaml:"api" json:"api" validate:"required"`
+	Ezr     []EzrConfig       `mapstructure:"ezr" yaml:"ezr" json:"ezr" validate:"required"`
+	General GeneralConfig     `mapstructure:"general" yaml:"general" json:"general" validate:"required"`
 }
 
 // DefaultConfig provides the default configuration. The configuration
@@ -23,10 +23,12 @@ var DefaultConfig = BaseConfig{
 		Mqtt: &MqttSettingsConfig{
 			Urls:              []string{"mqtt://mqtt:1883"},
 			Prefix:            "ezr",
+			RoomTopics:        "number",
 			Group:             "ezr2mqtt",
 			ConnectTimeout:    "10s",
 			ConnectRetryDelay: "1s",
 			KeepAliveInterval: "60s",
+			SessionExpiry:     "1h",
 		},
 	},
 	Ezr: []EzrConfig{{
@@ -34,17 +36,34 @@ var DefaultConfig = BaseConfig{
 		Type: "mock",
 	}},
 	General: GeneralConfig{
-		PollEvery: "1m",
+		PollEvery:       "1m",
+		ActivePollEvery: "10s",
+		ActivePollFor:   "2m",
+		PollMaxBackoff:  "10m",
+		PollJitter:      "5s",
+		DebounceWindow:  "500ms",
+		DebounceMaxWait: "2s",
+		RefreshDelay:    "1s",
+		DrainTimeout:    "10s",
 	},
 }
 
-// Load reads YAML configuration from a reader.
+// Load reads YAML configuration from a reader. References to environment
+// variables in the form ${NAME} are replaced by their values, only in the
+// values of the configuration.
 func (c *BaseConfig) Load(reader io.Reader) error {
 	b, err := io.ReadAll(reader)
 	if err != nil {
 		return err
 	}
-	if err := yaml.Unmarshal(b, c); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if err := expandEnvNode(&doc); err != nil {
+		return err
+	}
+	if err := doc.Decode(c); err != nil {
 		return err
 	}
 	return nil
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/chrishrb/ezr2mqtt/api"
@@ -19,10 +20,13 @@ import (
 type Config struct {
 	Store             store.Store
 	EzrClient         map[string]transport.Client
+	MqttConnection    api.Connection
 	MqttListener      api.Listener
 	MqttEmitter       api.Emitter
-	MqttHandler       api.MessageHandler
+	MqttHandler       *handlers.Debouncer
+	HandlerRouter     *handlers.HandlerRouter
 	PeriodicRequester []*polling.Poller
+	DrainTimeout      time.Duration
 }
 
 func Configure(ctx context.Context, cfg *BaseConfig) (c *Config, err error) {
@@ -43,23 +47,45 @@ func Configure(ctx context.Context, cfg *BaseConfig) (c *Config, err error) {
 		}
 	}
 
-	c.MqttListener, err = getMqttReceiver(cfg.Api)
+	mqttClient, err := getMqttClient(cfg.Api)
 	if err != nil {
 		return nil, err
 	}
+	c.MqttConnection = mqttClient
 
-	c.MqttEmitter, err = getMqttEmitter(cfg.Api)
+	c.MqttListener, err = getMqttReceiver(cfg.Api, mqttClient)
 	if err != nil {
 		return nil, err
 	}
 
-	c.MqttHandler = handlers.NewHandlerRouter(c.EzrClient, c.MqttEmitter, c.Store)
+	c.MqttEmitter, err = getMqttEmitter(cfg.Api, mqttClient)
+	if err != nil {
+		return nil, err
+	}
 
 	c.PeriodicRequester, err = getPeriodicRequesters(c.EzrClient, c.MqttEmitter, c.Store, cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	handlerOpts, err := getHandlerOpts(cfg.Ezr)
+	if err != nil {
+		return nil, err
+	}
+	for i, ezrCfg := range cfg.Ezr {
+		handlerOpts = append(handlerOpts, handlers.WithRefresher(ezrCfg.Name, c.PeriodicRequester[i]))
+	}
+	c.HandlerRouter = handlers.NewHandlerRouter(c.EzrClient, c.MqttEmitter, c.Store, handlerOpts...)
+	c.MqttHandler, err = getDebouncer(c.HandlerRouter, c.Store, cfg.General)
+	if err != nil {
+		return nil, err
+	}
+
+	c.DrainTimeout, err = time.ParseDuration(cfg.General.DrainTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse drain timeout: %w", err)
+	}
+
 	return c, nil
 }
 
@@ -74,109 +100,204 @@ func getEzrClient(cfg EzrConfig) (transport.Client, error) {
 	}
 }
 
-func getMqttReceiver(cfg ApiSettingsConfig) (api.Listener, error) {
-	switch cfg.Type {
-	case "mqtt":
-		var mqttUrls []*url.URL
-		for _, urlStr := range cfg.Mqtt.Urls {
-			u, err := url.Parse(urlStr)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse mqtt url: %w", err)
+func getHandlerOpts(cfgs []EzrConfig) ([]handlers.Opt, error) {
+	var opts []handlers.Opt
+	for _, cfg := range cfgs {
+		limits := handlers.Limits{
+			TemperatureStep: cfg.TemperatureStep,
+			Rooms:           make(map[string]handlers.Bounds),
+		}
+		for _, room := range cfg.Rooms {
+			if room.TemperatureMin != nil && room.TemperatureMax != nil && *room.TemperatureMin > *room.TemperatureMax {
+				return nil, fmt.Errorf("temperature_min of room %s of %s is above its temperature_max", room.Room, cfg.Name)
 			}
-			mqttUrls = append(mqttUrls, u)
+			// rooms are matched by number or slug, see api.Slug
+			key := room.Room
+			if _, err := strconv.Atoi(key); err != nil {
+				key = api.Slug(key)
+			}
+			limits.Rooms[key] = handlers.Bounds{Min: room.TemperatureMin, Max: room.TemperatureMax}
 		}
-
-		mqttConnectTimeout, err := time.ParseDuration(cfg.Mqtt.ConnectTimeout)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse mqtt connect timeout: %w", err)
+		opts = append(opts, handlers.WithLimits(cfg.Name, limits))
+		if cfg.InstallerSettings {
+			opts = append(opts, handlers.WithInstallerSettings(cfg.Name))
 		}
+	}
+	return opts, nil
+}
 
-		mqttConnectRetryDelay, err := time.ParseDuration(cfg.Mqtt.ConnectRetryDelay)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse mqtt connect retry delay: %w", err)
-		}
+func getDebouncer(next api.MessageHandler, store store.Store, cfg GeneralConfig) (*handlers.Debouncer, error) {
+	window, err := time.ParseDuration(cfg.DebounceWindow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse debounce window: %w", err)
+	}
 
-		mqttKeepAliveInterval, err := time.ParseDuration(cfg.Mqtt.KeepAliveInterval)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse mqtt keep alive interval: %w", err)
-		}
+	maxWait, err := time.ParseDuration(cfg.DebounceMaxWait)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse debounce max wait: %w", err)
+	}
 
-		opts := []mqtt.Opt[mqtt.Listener]{
-			mqtt.WithMqttBrokerUrls[mqtt.Listener](mqttUrls),
-			mqtt.WithMqttPrefix[mqtt.Listener](cfg.Mqtt.Prefix),
-			mqtt.WithMqttConnectSettings[mqtt.Listener](mqttConnectTimeout, mqttConnectRetryDelay, mqttKeepAliveInterval),
-			mqtt.WithMqttGroup(cfg.Mqtt.Group),
-		}
+	return handlers.NewDebouncer(next, store, window, maxWait), nil
+}
 
-		if cfg.Mqtt.Username != nil {
-			opts = append(opts, mqtt.WithMqttUsername[mqtt.Listener](*cfg.Mqtt.Username))
-		}
+type mqttConnection struct {
+	urls              []*url.URL
+	username          *string
+	password          *string
+	connectTimeout    time.Duration
+	connectRetryDelay time.Duration
+	keepAliveInterval time.Duration
+}
 
-		if cfg.Mqtt.Password != nil {
-			opts = append(opts, mqtt.WithMqttPassword[mqtt.Listener](*cfg.Mqtt.Password))
-		}
+func getMqttConnection(cfg *MqttSettingsConfig) (*mqttConnection, error) {
+	urls, err := parseMqttUrls(cfg.Urls)
+	if err != nil {
+		return nil, err
+	}
 
-		return mqtt.NewListener(opts...), nil
-	default:
-		return nil, fmt.Errorf("unsupported api type: %s", cfg.Type)
+	c := &mqttConnection{urls: stripUserinfo(urls)}
+
+	c.username, c.password, err = mqttCredentials(cfg, urls)
+	if err != nil {
+		return nil, err
+	}
+
+	c.connectTimeout, err = time.ParseDuration(cfg.ConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mqtt connect timeout: %w", err)
 	}
+
+	c.connectRetryDelay, err = time.ParseDuration(cfg.ConnectRetryDelay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mqtt connect retry delay: %w", err)
+	}
+
+	c.keepAliveInterval, err = time.ParseDuration(cfg.KeepAliveInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mqtt keep alive interval: %w", err)
+	}
+
+	return c, nil
 }
-func getMqttEmitter(cfg ApiSettingsConfig) (api.Emitter, error) {
+
+func getMqttClient(cfg ApiSettingsConfig) (*mqtt.Client, error) {
 	switch cfg.Type {
 	case "mqtt":
-		var mqttUrls []*url.URL
-		for _, urlStr := range cfg.Mqtt.Urls {
-			u, err := url.Parse(urlStr)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse mqtt url: %w", err)
-			}
-			mqttUrls = append(mqttUrls, u)
-		}
-
-		mqttConnectTimeout, err := time.ParseDuration(cfg.Mqtt.ConnectTimeout)
+		conn, err := getMqttConnection(cfg.Mqtt)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse mqtt connect timeout: %w", err)
+			return nil, err
 		}
 
-		mqttConnectRetryDelay, err := time.ParseDuration(cfg.Mqtt.ConnectRetryDelay)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse mqtt connect retry delay: %w", err)
+		opts := []mqtt.Opt[mqtt.Client]{
+			mqtt.WithMqttBrokerUrls[mqtt.Client](conn.urls),
+			mqtt.WithMqttPrefix[mqtt.Client](cfg.Mqtt.Prefix),
+			mqtt.WithMqttConnectSettings[mqtt.Client](conn.connectTimeout, conn.connectRetryDelay, conn.keepAliveInterval),
+			mqtt.WithMqttGroup[mqtt.Client](cfg.Mqtt.Group),
 		}
 
-		mqttKeepAliveInterval, err := time.ParseDuration(cfg.Mqtt.KeepAliveInterval)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse mqtt keep alive interval: %w", err)
+		if conn.username != nil {
+			opts = append(opts, mqtt.WithMqttUsername[mqtt.Client](*conn.username))
 		}
 
-		opts := []mqtt.Opt[mqtt.Emitter]{
-			mqtt.WithMqttBrokerUrls[mqtt.Emitter](mqttUrls),
-			mqtt.WithMqttPrefix[mqtt.Emitter](cfg.Mqtt.Prefix),
-			mqtt.WithMqttConnectSettings[mqtt.Emitter](mqttConnectTimeout, mqttConnectRetryDelay, mqttKeepAliveInterval),
+		if conn.password != nil {
+			opts = append(opts, mqtt.WithMqttPassword[mqtt.Client](*conn.password))
 		}
 
-		if cfg.Mqtt.Username != nil {
-			opts = append(opts, mqtt.WithMqttUsername[mqtt.Emitter](*cfg.Mqtt.Username))
+		if cfg.Mqtt.ClientID != nil {
+			opts = append(opts, mqtt.WithMqttClientID[mqtt.Client](*cfg.Mqtt.ClientID))
 		}
 
-		if cfg.Mqtt.Password != nil {
-			opts = append(opts, mqtt.WithMqttPassword[mqtt.Emitter](*cfg.Mqtt.Password))
+		if cfg.Mqtt.PersistentSession {
+			sessionExpiry, err := time.ParseDuration(cfg.Mqtt.SessionExpiry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse mqtt session expiry: %w", err)
+			}
+			opts = append(opts, mqtt.WithMqttPersistentSession[mqtt.Client](sessionExpiry))
 		}
 
-		return mqtt.NewEmitter(opts...), nil
+		return mqtt.NewClient(opts...), nil
+	default:
+		return nil, fmt.Errorf("unsupported api type: %s", cfg.Type)
+	}
+}
+
+func getMqttReceiver(cfg ApiSettingsConfig, client *mqtt.Client) (api.Listener, error) {
+	switch cfg.Type {
+	case "mqtt":
+		return mqtt.NewListener(
+			mqtt.WithClient[mqtt.Listener](client),
+			mqtt.WithMqttPrefix[mqtt.Listener](cfg.Mqtt.Prefix),
+		), nil
+	default:
+		return nil, fmt.Errorf("unsupported api type: %s", cfg.Type)
+	}
+}
+
+func getMqttEmitter(cfg ApiSettingsConfig, client *mqtt.Client) (api.Emitter, error) {
+	switch cfg.Type {
+	case "mqtt":
+		return mqtt.NewEmitter(
+			mqtt.WithClient[mqtt.Emitter](client),
+			mqtt.WithMqttPrefix[mqtt.Emitter](cfg.Mqtt.Prefix),
+			mqtt.WithMqttRoomTopics[mqtt.Emitter](mqtt.RoomTopics(cfg.Mqtt.RoomTopics)),
+		), nil
 	default:
 		return nil, fmt.Errorf("unsupported api type: %s", cfg.Type)
 	}
 }
 
 func getPeriodicRequesters(clients map[string]transport.Client, emitter api.Emitter, store store.Store, cfg *BaseConfig) ([]*polling.Poller, error) {
-	runEvery, err := time.ParseDuration(cfg.General.PollEvery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse periodic PollEvery: %w", err)
+	var runEvery, refreshDelay, activeEvery, activeFor, maxBackoff, jitter time.Duration
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"poll_every", cfg.General.PollEvery, &runEvery},
+		{"refresh_delay", cfg.General.RefreshDelay, &refreshDelay},
+		{"active_poll_every", cfg.General.ActivePollEvery, &activeEvery},
+		{"active_poll_for", cfg.General.ActivePollFor, &activeFor},
+		{"poll_max_backoff", cfg.General.PollMaxBackoff, &maxBackoff},
+		{"poll_jitter", cfg.General.PollJitter, &jitter},
+	} {
+		var err error
+		*d.dst, err = time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", d.name, err)
+		}
 	}
 
 	periodicRequesters := make([]*polling.Poller, len(cfg.Ezr))
 	for i, ezrCfg := range cfg.Ezr {
-		periodicRequesters[i] = polling.NewPoller(ezrCfg.Name, clients[ezrCfg.Name], emitter, runEvery, store)
+		deviceRunEvery := runEvery
+		if ezrCfg.PollEvery != "" {
+			var err error
+			deviceRunEvery, err = time.ParseDuration(ezrCfg.PollEvery)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse poll_every of %s: %w", ezrCfg.Name, err)
+			}
+		}
+
+		opts := []polling.Opt{
+			polling.WithRefreshDelay(refreshDelay),
+			polling.WithActivePolling(activeEvery, activeFor),
+			polling.WithBackoff(maxBackoff),
+			polling.WithJitter(jitter),
+		}
+		if ezrCfg.TemperatureStep > 0 {
+			opts = append(opts, polling.WithTemperatureStep(ezrCfg.TemperatureStep))
+		}
+		if ezrCfg.MaxClockDrift != "" {
+			maxDrift, err := time.ParseDuration(ezrCfg.MaxClockDrift)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse max_clock_drift of %s: %w", ezrCfg.Name, err)
+			}
+			opts = append(opts, polling.WithClockSync(maxDrift))
+		}
+		if ezrCfg.InstallerSettings {
+			opts = append(opts, polling.WithInstallerSettings())
+		}
+		periodicRequesters[i] = polling.NewPoller(ezrCfg.Name, clients[ezrCfg.Name], emitter, deviceRunEvery, store, opts...)
 	}
 
 	return periodicRequesters, nil
@@ -14,3 +14,77 @@ func TestConfigure(t *testing.T) {
 	_, err := config.Configure(t.Context(), cfg)
 	require.NoError(t, err)
 }
+
+func TestValidate_PasswordAndPasswordFileAreExclusive(t *testing.T) {
+	cfg := clone.Clone(&config.DefaultConfig)
+	password, passwordFile := "pass", "/run/secrets/mqtt_password"
+	cfg.Api.Mqtt.Password = &password
+	cfg.Api.Mqtt.PasswordFile = &passwordFile
+
+	err := cfg.Validate()
+	require.Error(t, err)
+}
+
+func TestValidate_RoomTopics(t *testing.T) {
+	cfg := clone.Clone(&config.DefaultConfig)
+	cfg.Api.Mqtt.RoomTopics = "name"
+	require.NoError(t, cfg.Validate())
+
+	cfg.Api.Mqtt.RoomTopics = "slug"
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfigure_RoomTemperatureBounds(t *testing.T) {
+	cfg := clone.Clone(&config.DefaultConfig)
+	minimum, maximum := 22.0, 18.0
+	cfg.Ezr[0].Rooms = []config.RoomConfig{{Room: "Kinderzimmer", TemperatureMin: &minimum, TemperatureMax: &maximum}}
+
+	_, err := config.Configure(t.Context(), cfg)
+	require.Error(t, err)
+
+	cfg.Ezr[0].Rooms[0].TemperatureMin = nil
+	_, err = config.Configure(t.Context(), cfg)
+	require.NoError(t, err)
+}
+
+func TestConfigure_InvalidDebounceWindow(t *testing.T) {
+	cfg := clone.Clone(&config.DefaultConfig)
+	cfg.General.DebounceWindow = "soon"
+
+	_, err := config.Configure(t.Context(), cfg)
+	require.Error(t, err)
+}
+
+func TestConfigure_InvalidDevicePollEvery(t *testing.T) {
+	cfg := clone.Clone(&config.DefaultConfig)
+	cfg.Ezr[0].PollEvery = "often"
+
+	_, err := config.Configure(t.Context(), cfg)
+	require.Error(t, err)
+}
+
+func TestConfigure_InvalidMaxClockDrift(t *testing.T) {
+	cfg := clone.Clone(&config.DefaultConfig)
+	cfg.Ezr[0].MaxClockDrift = "a bit"
+
+	_, err := config.Configure(t.Context(), cfg)
+	require.Error(t, err)
+}
+
+func TestConfigure_InstallerSettings(t *testing.T) {
+	cfg := clone.Clone(&config.DefaultConfig)
+	cfg.Ezr[0].InstallerSettings = true
+
+	c, err := config.Configure(t.Context(), cfg)
+	require.NoError(t, err)
+	require.Len(t, c.PeriodicRequester, 1)
+}
+
+func TestConfigure_Example(t *testing.T) {
+	t.Setenv("MQTT_PASSWORD", "pass")
+	cfg := clone.Clone(&config.DefaultConfig)
+	require.NoError(t, cfg.LoadFromFile("../ezr2mqtt.example.yaml"))
+
+	_, err := config.Configure(t.Context(), cfg)
+	require.NoError(t, err)
+}
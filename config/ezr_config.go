@@ -1,11 +1,25 @@
 package config
 
 type HttpClientConfig struct {
-	Host string `mapstructure:"host" json:"host" validate:"required"`
+	Host string `mapstructure:"host" yaml:"host" json:"host" validate:"required"`
 }
 
 type EzrConfig struct {
-	Name string            `mapstructure:"name" json:"name" validate:"required"`
-	Type string            `mapstructure:"type" toml:"type" validate:"required,oneof=http mock"`
-	Http *HttpClientConfig `mapstructure:"http,omitempty" toml:"http,omitempty" validate:"required_if=Type http"`
+	Name string            `mapstructure:"name" yaml:"name" json:"name" validate:"required,ne=all"`
+	Type string            `mapstructure:"type" yaml:"type" toml:"type" validate:"required,oneof=http mock"`
+	Http *HttpClientConfig `mapstructure:"http,omitempty" yaml:"http,omitempty" toml:"http,omitempty" validate:"required_if=Type http"`
+
+	PollEvery         string       `mapstructure:"poll_every,omitempty" yaml:"poll_every,omitempty" json:"poll_every,omitempty"`
+	MaxClockDrift     string       `mapstructure:"max_clock_drift,omitempty" yaml:"max_clock_drift,omitempty" json:"max_clock_drift,omitempty"`
+	InstallerSettings bool         `mapstructure:"installer_settings,omitempty" yaml:"installer_settings,omitempty" json:"installer_settings,omitempty"`
+	TemperatureStep   float64      `mapstructure:"temperature_step,omitempty" yaml:"temperature_step,omitempty" json:"temperature_step,omitempty" validate:"omitempty,gt=0,lte=1"`
+	Rooms             []RoomConfig `mapstructure:"rooms,omitempty" yaml:"rooms,omitempty" json:"rooms,omitempty" validate:"dive"`
+}
+
+// RoomConfig holds user defined settings of a room, which is identified
+// by its number or its name.
+type RoomConfig struct {
+	Room           string   `mapstructure:"room" yaml:"room" json:"room" validate:"required"`
+	TemperatureMin *float64 `mapstructure:"temperature_min,omitempty" yaml:"temperature_min,omitempty" json:"temperature_min,omitempty"`
+	TemperatureMax *float64 `mapstructure:"temperature_max,omitempty" yaml:"temperature_max,omitempty" json:"temperature_max,omitempty"`
 }
@@ -1,5 +1,13 @@
 package config
 
 type GeneralConfig struct {
-	PollEvery string `mapstructure:"poll_every" json:"poll_every" validate:"required,gt=0"`
+	PollEvery       string `mapstructure:"poll_every" yaml:"poll_every" json:"poll_every" validate:"required,gt=0"`
+	ActivePollEvery string `mapstructure:"active_poll_every" yaml:"active_poll_every" json:"active_poll_every" validate:"required"`
+	ActivePollFor   string `mapstructure:"active_poll_for" yaml:"active_poll_for" json:"active_poll_for" validate:"required"`
+	PollMaxBackoff  string `mapstructure:"poll_max_backoff" yaml:"poll_max_backoff" json:"poll_max_backoff" validate:"required"`
+	PollJitter      string `mapstructure:"poll_jitter" yaml:"poll_jitter" json:"poll_jitter" validate:"required"`
+	DebounceWindow  string `mapstructure:"debounce_window" yaml:"debounce_window" json:"debounce_window" validate:"required"`
+	DebounceMaxWait string `mapstructure:"debounce_max_wait" yaml:"debounce_max_wait" json:"debounce_max_wait" validate:"required"`
+	RefreshDelay    string `mapstructure:"refresh_delay" yaml:"refresh_delay" json:"refresh_delay" validate:"required"`
+	DrainTimeout    string `mapstructure:"drain_timeout" yaml:"drain_timeout" json:"drain_timeout" validate:"required"`
 }
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "xxxxx"
+
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces every ${NAME} in s with the value of the environment
+// variable NAME. Unlike os.ExpandEnv the bare $NAME form is left untouched,
+// so that passwords containing a dollar sign survive. Referencing a variable
+// that is not set is an error.
+func expandEnv(s string) (string, error) {
+	var missing []string
+	out := envPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := envPattern.FindStringSubmatch(m)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// expandEnvNode expands the references to environment variables in the
+// scalar values of a parsed YAML document. Keys and comments are left
+// untouched and the values are inserted as they are, so they can't change
+// the structure of the document. Plain scalars are resolved again, so that
+// e.g. a number can be set from the environment, but are kept as strings if
+// they turned into null.
+func expandEnvNode(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		out, err := expandEnv(n.Value)
+		if err != nil || out == n.Value {
+			return err
+		}
+		n.Value = out
+		if n.Style == 0 {
+			n.Tag = ""
+			if n.ShortTag() == "!!null" {
+				n.Tag = "!!str"
+			}
+		}
+	case yaml.MappingNode:
+		// only the values, every second node is a key
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := expandEnvNode(n.Content[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, c := range n.Content {
+			if err := expandEnvNode(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mqttCredentials determines the username and password used to connect to
+// the broker. Explicit settings win over the userinfo of the broker URLs:
+//
+//	username: username > URL userinfo
+//	password: password_file | password > URL userinfo
+//
+// password and password_file are mutually exclusive. All URLs carrying
+// userinfo must agree on it, since a single set of credentials is used.
+func mqttCredentials(cfg *MqttSettingsConfig, urls []*url.URL) (username, password *string, err error) {
+	var userinfo *url.Userinfo
+	for _, u := range urls {
+		if u.User == nil {
+			continue
+		}
+		if userinfo != nil && userinfo.String() != u.User.String() {
+			return nil, nil, fmt.Errorf("mqtt urls contain conflicting credentials")
+		}
+		userinfo = u.User
+	}
+
+	if userinfo != nil {
+		user := userinfo.Username()
+		username = &user
+		if pass, ok := userinfo.Password(); ok {
+			password = &pass
+		}
+	}
+
+	if cfg.Username != nil {
+		username = cfg.Username
+	}
+
+	switch {
+	case cfg.PasswordFile != nil:
+		pass, err := readSecretFile(*cfg.PasswordFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read mqtt password file: %w", err)
+		}
+		password = &pass
+	case cfg.Password != nil:
+		password = cfg.Password
+	}
+
+	return username, password, nil
+}
+
+// readSecretFile reads a secret from a file, e.g. a Docker secret mounted
+// to /run/secrets. A trailing newline is not considered part of the secret.
+func readSecretFile(path string) (string, error) {
+	//#nosec G304 - only files specified by the person running the application will be loaded
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// parseMqttUrls parses the broker URLs. The userinfo is kept, pass the
+// result through mqttCredentials and stripUserinfo before using it.
+func parseMqttUrls(urlStrs []string) ([]*url.URL, error) {
+	var urls []*url.URL
+	for _, urlStr := range urlStrs {
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			// the error contains the URL, so don't wrap it
+			return nil, fmt.Errorf("failed to parse mqtt url %s", redactURL(urlStr))
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// stripUserinfo returns copies of urls without userinfo, so that the
+// credentials can't leak through anything printing them.
+func stripUserinfo(urls []*url.URL) []*url.URL {
+	stripped := make([]*url.URL, len(urls))
+	for i, u := range urls {
+		c := *u
+		c.User = nil
+		stripped[i] = &c
+	}
+	return stripped
+}
+
+// redactURL replaces the password in the URL's userinfo with a placeholder.
+// Strings that can't be parsed are redacted entirely as they might still
+// contain a password.
+func redactURL(urlStr string) string {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return redacted
+	}
+	return u.Redacted()
+}
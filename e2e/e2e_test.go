@@ -4,8 +4,10 @@ package e2e_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -58,13 +60,18 @@ func TestE2E_SetTargetTemperatureOverMQTT(t *testing.T) {
 	// Get initial state to store device ID
 	initialMsg, err := mockClient.Connect()
 	require.NoError(t, err)
-	memStore.SetID(deviceName, initialMsg.Device.ID)
+	memStore.SetID(deviceName, *initialMsg.Device.ID)
+	memStore.SetDevice(deviceName, &initialMsg.Device)
 
 	// Create handler router
 	clients := map[string]transport.Client{
 		deviceName: mockClient,
 	}
-	handlerRouter := handlers.NewHandlerRouter(clients, memStore)
+	emitter := mqtt.NewEmitter(
+		mqtt.WithMqttBrokerUrl[mqtt.Emitter](brokerURL),
+		mqtt.WithMqttPrefix[mqtt.Emitter](mqttPrefix),
+	)
+	handlerRouter := handlers.NewHandlerRouter(clients, emitter, memStore)
 
 	// Create MQTT listener
 	listener := mqtt.NewListener(
@@ -129,9 +136,9 @@ func TestE2E_SetTargetTemperatureOverMQTT(t *testing.T) {
 
 	// Find the heat area and verify temperature
 	var found bool
-	for _, heatArea := range updatedMsg.Device.HeatAreas {
-		if heatArea.Nr == roomNr {
-			assert.Equal(t, newTargetTemp, heatArea.TTarget, "Target temperature should be updated")
+	for _, heatArea := range *updatedMsg.Device.HeatAreas {
+		if *heatArea.Nr == roomNr {
+			assert.Equal(t, newTargetTemp, *heatArea.TTarget, "Target temperature should be updated")
 			found = true
 			break
 		}
@@ -167,14 +174,23 @@ func TestE2E_TemperaturePublishedToMQTT(t *testing.T) {
 	// Create mock EZR client
 	mockClient := mock.NewMockClient()
 
+	// Secrets of the controller, which must not be published
+	cloudPassword, expertCode := "cloud-s3cret", "4711"
+	err := mockClient.Send(&transport.Message{Device: transport.Device{
+		Cloud: &transport.Cloud{Password: &cloudPassword},
+		Code:  &transport.Code{Expert: &expertCode},
+	}})
+	require.NoError(t, err)
+
 	// Create store
 	memStore := store.NewInMemoryStore()
 
 	// Get initial state to store device ID
 	initialMsg, err := mockClient.Connect()
 	require.NoError(t, err)
-	deviceID := initialMsg.Device.ID
+	deviceID := *initialMsg.Device.ID
 	memStore.SetID(deviceName, deviceID)
+	memStore.SetDevice(deviceName, &initialMsg.Device)
 
 	// Create MQTT emitter
 	emitter := mqtt.NewEmitter(
@@ -183,14 +199,18 @@ func TestE2E_TemperaturePublishedToMQTT(t *testing.T) {
 	)
 
 	// Create a test MQTT client to subscribe to temperature updates
-	messageChan := make(chan *paho.Publish, 10)
+	messageChan := make(chan *paho.Publish, 100)
 	router := paho.NewStandardRouter()
 
 	// Subscribe to state topics for all rooms
 	// Topic format: ezr/{device_id}/+/state/+
 	stateTopic := fmt.Sprintf("%s/%s/+/state/+", mqttPrefix, deviceName)
 	router.RegisterHandler(stateTopic, func(p *paho.Publish) {
-		messageChan <- p
+		select {
+		case messageChan <- p:
+		default:
+			// don't block the client once collecting is done
+		}
 	})
 
 	testClient, err := autopaho.NewConnection(context.Background(), autopaho.ClientConfig{
@@ -239,7 +259,7 @@ func TestE2E_TemperaturePublishedToMQTT(t *testing.T) {
 
 	// Define expected message types per heat area
 	expectedMessageTypes := []string{"temperature_target", "temperature_actual", "heatarea_mode"}
-	numRooms := len(initialMsg.Device.HeatAreas)
+	numRooms := len(*initialMsg.Device.HeatAreas)
 	numMetaMessages := 1
 	expectedMinMessages := numMetaMessages + (numRooms * len(expectedMessageTypes))
 
@@ -250,6 +270,9 @@ collecting:
 			topicParts := strings.Split(msg.Topic, "/")
 
 			tp := topicParts[len(topicParts)-1]
+			if !slices.Contains(expectedMessageTypes, tp) && tp != "meta" {
+				continue
+			}
 			room, err := strconv.Atoi(topicParts[len(topicParts)-3])
 			require.NoError(t, err)
 
@@ -268,35 +291,35 @@ collecting:
 		"Should receive at least %d messages (meta + temperature data)", expectedMinMessages)
 
 	// Verify messages for each room
-	for _, heatArea := range initialMsg.Device.HeatAreas {
+	for _, heatArea := range *initialMsg.Device.HeatAreas {
 		// Verify temperature_target
-		targetTopic := fmt.Sprintf("%s/%s/%d/state/temperature_target", mqttPrefix, deviceName, heatArea.Nr)
+		targetTopic := fmt.Sprintf("%s/%s/%d/state/temperature_target", mqttPrefix, deviceName, *heatArea.Nr)
 		if msg, ok := receivedMessages[targetTopic]; ok {
-			assert.Equal(t, heatArea.Nr, msg.Room, "Room number should match")
+			assert.Equal(t, *heatArea.Nr, msg.Room, "Room number should match")
 			assert.Equal(t, "temperature_target", msg.Type, "Message type should be temperature_target")
-			assert.Equal(t, api.FormatFloat(heatArea.TTarget), msg.Data, "Target temperature should match")
+			assert.Equal(t, api.FormatFloat(*heatArea.TTarget), msg.Data, "Target temperature should match")
 		} else {
-			t.Errorf("Expected to receive temperature_target message for room %d on topic %s", heatArea.Nr, targetTopic)
+			t.Errorf("Expected to receive temperature_target message for room %d on topic %s", *heatArea.Nr, targetTopic)
 		}
 
 		// Verify temperature_actual
-		actualTopic := fmt.Sprintf("%s/%s/%d/state/temperature_actual", mqttPrefix, deviceName, heatArea.Nr)
+		actualTopic := fmt.Sprintf("%s/%s/%d/state/temperature_actual", mqttPrefix, deviceName, *heatArea.Nr)
 		if msg, ok := receivedMessages[actualTopic]; ok {
-			assert.Equal(t, heatArea.Nr, msg.Room, "Room number should match")
+			assert.Equal(t, *heatArea.Nr, msg.Room, "Room number should match")
 			assert.Equal(t, "temperature_actual", msg.Type, "Message type should be temperature_actual")
-			assert.Equal(t, api.FormatFloat(heatArea.TActual), msg.Data, "Actual temperature should match")
+			assert.Equal(t, api.FormatFloat(*heatArea.TActual), msg.Data, "Actual temperature should match")
 		} else {
-			t.Errorf("Expected to receive temperature_actual message for room %d on topic %s", heatArea.Nr, actualTopic)
+			t.Errorf("Expected to receive temperature_actual message for room %d on topic %s", *heatArea.Nr, actualTopic)
 		}
 
 		// Verify heatarea_mode
-		modeTopic := fmt.Sprintf("%s/%s/%d/state/heatarea_mode", mqttPrefix, deviceName, heatArea.Nr)
+		modeTopic := fmt.Sprintf("%s/%s/%d/state/heatarea_mode", mqttPrefix, deviceName, *heatArea.Nr)
 		if msg, ok := receivedMessages[modeTopic]; ok {
-			assert.Equal(t, heatArea.Nr, msg.Room, "Room number should match")
+			assert.Equal(t, *heatArea.Nr, msg.Room, "Room number should match")
 			assert.Equal(t, "heatarea_mode", msg.Type, "Message type should be heatarea_mode")
 			assert.Equal(t, "day", msg.Data, "Heat area mode should match")
 		} else {
-			t.Errorf("Expected to receive heatarea_mode message for room %d on topic %s", heatArea.Nr, modeTopic)
+			t.Errorf("Expected to receive heatarea_mode message for room %d on topic %s", *heatArea.Nr, modeTopic)
 		}
 	}
 
@@ -306,6 +329,16 @@ collecting:
 		assert.Equal(t, 0, msg.Room, "Meta message should have room 0")
 		assert.Equal(t, "meta", msg.Type, "Message type should be meta")
 		assert.NotNil(t, msg.Data, "Meta data should not be nil")
+
+		// Verify the secrets are redacted
+		var meta api.Meta
+		require.NoError(t, json.Unmarshal([]byte(msg.Data), &meta))
+		require.NotNil(t, meta.Cloud, "Meta data should contain the cloud settings")
+		assert.Equal(t, api.Redacted, *meta.Cloud.Password, "Cloud password should be redacted")
+		require.NotNil(t, meta.ExpertCode, "Meta data should contain the expert code")
+		assert.Equal(t, api.Redacted, *meta.ExpertCode, "Expert code should be redacted")
+		assert.NotContains(t, msg.Data, cloudPassword, "Meta data should not contain the cloud password")
+		assert.NotContains(t, msg.Data, expertCode, "Meta data should not contain the expert code")
 	} else {
 		t.Errorf("Expected to receive meta message on topic %s", metaTopic)
 	}
@@ -345,13 +378,14 @@ func TestE2E_SetModeOverMQTT(t *testing.T) {
 	// Get initial state to store device ID
 	initialMsg, err := mockClient.Connect()
 	require.NoError(t, err)
-	memStore.SetID(deviceName, initialMsg.Device.ID)
+	memStore.SetID(deviceName, *initialMsg.Device.ID)
+	memStore.SetDevice(deviceName, &initialMsg.Device)
 
 	// Get initial mode for room 1
 	var initialMode int
-	for _, ha := range initialMsg.Device.HeatAreas {
-		if ha.Nr == roomNr {
-			initialMode = ha.Mode
+	for _, ha := range *initialMsg.Device.HeatAreas {
+		if *ha.Nr == roomNr {
+			initialMode = *ha.Mode
 			break
 		}
 	}
@@ -360,7 +394,11 @@ func TestE2E_SetModeOverMQTT(t *testing.T) {
 	clients := map[string]transport.Client{
 		deviceName: mockClient,
 	}
-	handlerRouter := handlers.NewHandlerRouter(clients, memStore)
+	emitter := mqtt.NewEmitter(
+		mqtt.WithMqttBrokerUrl[mqtt.Emitter](brokerURL),
+		mqtt.WithMqttPrefix[mqtt.Emitter](mqttPrefix),
+	)
+	handlerRouter := handlers.NewHandlerRouter(clients, emitter, memStore)
 
 	// Create MQTT listener
 	listener := mqtt.NewListener(
@@ -434,9 +472,9 @@ func TestE2E_SetModeOverMQTT(t *testing.T) {
 
 			// Find the heat area and verify mode
 			var found bool
-			for _, heatArea := range updatedMsg.Device.HeatAreas {
-				if heatArea.Nr == roomNr {
-					assert.Equal(t, tc.expectedMode, heatArea.Mode, "Mode should be updated to %s (%d)", tc.mode, tc.expectedMode)
+			for _, heatArea := range *updatedMsg.Device.HeatAreas {
+				if *heatArea.Nr == roomNr {
+					assert.Equal(t, tc.expectedMode, *heatArea.Mode, "Mode should be updated to %s (%d)", tc.mode, tc.expectedMode)
 					found = true
 					break
 				}
@@ -489,14 +527,15 @@ func TestE2E_FullIntegration(t *testing.T) {
 	// Get initial state
 	initialMsg, err := mockClient.Connect()
 	require.NoError(t, err)
-	deviceID := initialMsg.Device.ID
+	deviceID := *initialMsg.Device.ID
 	memStore.SetID(deviceName, deviceID)
+	memStore.SetDevice(deviceName, &initialMsg.Device)
 
 	// Get initial target temperature for room 1
 	var initialTargetTemp float64
-	for _, ha := range initialMsg.Device.HeatAreas {
-		if ha.Nr == roomNr {
-			initialTargetTemp = ha.TTarget
+	for _, ha := range *initialMsg.Device.HeatAreas {
+		if *ha.Nr == roomNr {
+			initialTargetTemp = *ha.TTarget
 			break
 		}
 	}
@@ -511,7 +550,7 @@ func TestE2E_FullIntegration(t *testing.T) {
 	clients := map[string]transport.Client{
 		deviceName: mockClient,
 	}
-	handlerRouter := handlers.NewHandlerRouter(clients, memStore)
+	handlerRouter := handlers.NewHandlerRouter(clients, emitter, memStore)
 
 	// Create MQTT listener
 	listener := mqtt.NewListener(
@@ -533,12 +572,16 @@ func TestE2E_FullIntegration(t *testing.T) {
 	}()
 
 	// Create test MQTT client for publishing and subscribing
-	messageChan := make(chan *paho.Publish, 10)
+	messageChan := make(chan *paho.Publish, 100)
 	router := paho.NewStandardRouter()
 
 	stateTopic := fmt.Sprintf("%s/%s/+/state/+", mqttPrefix, deviceName)
 	router.RegisterHandler(stateTopic, func(p *paho.Publish) {
-		messageChan <- p
+		select {
+		case messageChan <- p:
+		default:
+			// don't block the client once collecting is done
+		}
 	})
 
 	testClient, err := autopaho.NewConnection(context.Background(), autopaho.ClientConfig{
@@ -623,9 +666,9 @@ func TestE2E_FullIntegration(t *testing.T) {
 	finalMsg, err := mockClient.Connect()
 	require.NoError(t, err)
 
-	for _, ha := range finalMsg.Device.HeatAreas {
-		if ha.Nr == roomNr {
-			assert.Equal(t, newTargetTemp, ha.TTarget,
+	for _, ha := range *finalMsg.Device.HeatAreas {
+		if *ha.Nr == roomNr {
+			assert.Equal(t, newTargetTemp, *ha.TTarget,
 				"Mock client should have the updated target temperature")
 			break
 		}
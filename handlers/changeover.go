@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// setCooling switches the whole system between heating ("off") and cooling
+// ("on"). Cooling is rejected if the controller has changeover disabled.
+func setCooling(t target, change *transport.Device) error {
+	cooling, err := parseOnOff(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("unknown cooling value: %s", t.message.Data)
+	}
+	if t.device == nil {
+		return errors.New("changeover is unknown as the device was not polled yet")
+	}
+	if cooling == 1 && t.device.Changeover != nil && *t.device.Changeover == 0 {
+		return errors.New("cooling requires changeover to be enabled")
+	}
+
+	change.Cooling = &cooling
+	return nil
+}
+
+func setChangeover(t target, change *transport.Device) error {
+	changeover, err := parseOnOff(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("unknown changeover value: %s", t.message.Data)
+	}
+
+	change.Changeover = &changeover
+	return nil
+}
+
+func setSummerWinter(t target, change *transport.Device) error {
+	summerWinter, err := parseOnOff(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("unknown summer/winter value: %s", t.message.Data)
+	}
+
+	change.SummerWinter = &summerWinter
+	return nil
+}
+
+// setDeviceMode sets the operating mode of the controller, which is passed
+// through as the raw value of the controller.
+func setDeviceMode(t target, change *transport.Device) error {
+	mode, err := parseMode(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("invalid device mode: %w", err)
+	}
+
+	change.Mode = &mode
+	return nil
+}
+
+// setChangeoverMode sets how the controller switches between heating and
+// cooling, which is passed through as the raw value of the controller.
+func setChangeoverMode(t target, change *transport.Device) error {
+	mode, err := parseMode(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("invalid changeover mode: %w", err)
+	}
+
+	change.ChangeoverFunc = &transport.ChangeoverFunc{Mode: &mode}
+	return nil
+}
+
+func parseMode(data string) (int, error) {
+	mode, err := strconv.Atoi(data)
+	if err != nil {
+		return 0, fmt.Errorf("not a number: %s", data)
+	}
+	if mode < 0 {
+		return 0, fmt.Errorf("%d is negative", mode)
+	}
+	return mode, nil
+}
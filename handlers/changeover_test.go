@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRouter_Handle_Changeover(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	for _, m := range []*api.Message{
+		{Room: api.DeviceRoom, Type: "cooling", Data: "on"},
+		{Room: api.DeviceRoom, Type: "summer_winter", Data: "on"},
+		{Room: api.DeviceRoom, Type: "device_mode", Data: "2"},
+		{Room: api.DeviceRoom, Type: "changeover_mode", Data: "1"},
+	} {
+		router.Handle(context.Background(), "device1", m)
+	}
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, 1, *res.Device.Cooling)
+	assert.Equal(t, 1, *res.Device.SummerWinter)
+	assert.Equal(t, 2, *res.Device.Mode)
+	assert.Equal(t, 1, *res.Device.ChangeoverFunc.Mode)
+	require.Len(t, emitter.messages, 4)
+	assert.Equal(t, api.DeviceRoom, emitter.messages[0].Room)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "changeover", Data: "off"})
+	res, err = client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, 0, *res.Device.Changeover)
+}
+
+func TestHandlerRouter_Handle_ChangeoverValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		room int
+		typ  string
+		data string
+	}{
+		{name: "invalid cooling", typ: "cooling", data: "cool"},
+		{name: "room", room: 1, typ: "cooling", data: "on"},
+		{name: "invalid changeover", typ: "changeover", data: "1"},
+		{name: "invalid summer/winter", typ: "summer_winter", data: "summer"},
+		{name: "invalid device mode", typ: "device_mode", data: "auto"},
+		{name: "negative changeover mode", typ: "changeover_mode", data: "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingClient{Client: mock.NewMockClient()}
+			router, emitter := newTestRouter(t, client)
+
+			router.Handle(context.Background(), "device1", &api.Message{Room: tt.room, Type: tt.typ, Data: tt.data})
+
+			assert.Zero(t, client.sent)
+			assert.Empty(t, emitter.messages)
+		})
+	}
+}
+
+func TestHandlerRouter_Handle_CoolingWithoutChangeover(t *testing.T) {
+	client := &countingClient{Client: mock.NewMockClient()}
+	err := client.Send(&transport.Message{Device: transport.Device{Changeover: ptr(0)}})
+	require.NoError(t, err)
+	client.sent = 0
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "cooling", Data: "on"})
+	assert.Zero(t, client.sent)
+	assert.Empty(t, emitter.messages)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "cooling", Data: "off"})
+	assert.Equal(t, 1, client.sent)
+}
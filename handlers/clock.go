@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// ClockSyncPayload is the payload of a clock_sync command, the default
+// payload of Home Assistant buttons.
+const ClockSyncPayload = "PRESS"
+
+// setClock sets the clock of the controller to now. The message is updated
+// with the time written to the controller.
+func setClock(t target, now time.Time, change *transport.Device) error {
+	if t.message.Data != ClockSyncPayload {
+		return fmt.Errorf("invalid clock sync: %q, expected %s", t.message.Data, ClockSyncPayload)
+	}
+	dateTime, dayOfWeek := transport.Clock(now)
+	change.DateTime = &dateTime
+	change.DayOfWeek = &dayOfWeek
+	t.message.Data = dateTime
+	return nil
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRouter_Handle_ClockSync(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	before := time.Now().Truncate(time.Second)
+	router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "clock_sync", Data: "PRESS"})
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	synced, err := transport.ParseClock(*res.Device.DateTime, time.Local)
+	require.NoError(t, err)
+	assert.WithinDuration(t, before, synced, 5*time.Second)
+	_, dayOfWeek := transport.Clock(synced)
+	assert.Equal(t, dayOfWeek, *res.Device.DayOfWeek)
+
+	require.Len(t, emitter.messages, 1)
+	assert.Equal(t, *res.Device.DateTime, emitter.messages[0].Data)
+}
+
+func TestHandlerRouter_Handle_ClockSyncInvalid(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "clock_sync", Data: "now"})
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-01T12:00:00", *res.Device.DateTime)
+	assert.Empty(t, emitter.messages)
+}
+
+func TestClock_DayOfWeek(t *testing.T) {
+	dateTime, dayOfWeek := transport.Clock(time.Date(2025, 12, 28, 18, 30, 5, 0, time.UTC))
+	assert.Equal(t, "2025-12-28T18:30:05", dateTime)
+	assert.Equal(t, 7, dayOfWeek)
+
+	_, dayOfWeek = transport.Clock(time.Date(2025, 12, 29, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 1, dayOfWeek)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/store"
+)
+
+// debouncedTypes are the message types carrying an absolute value, so that
+// only the last message of a burst matters. All other messages, e.g. partial
+// vacation or programs, are passed on right away.
+var debouncedTypes = map[string]bool{
+	"temperature_target":     true,
+	"temperature_heat_day":   true,
+	"temperature_heat_night": true,
+	"temperature_cool_day":   true,
+	"temperature_cool_night": true,
+	"temperature_floor_day":  true,
+}
+
+// Debouncer collapses bursts of messages for the same device, room and type,
+// e.g. from dragging a slider, into the last message. The message is passed
+// on once no other message arrived for window, but no later than maxWait
+// after the first message of the burst. A maxWait of zero disables the cap.
+// Only debouncedTypes are debounced.
+type Debouncer struct {
+	sync.Mutex
+	next    api.MessageHandler
+	store   store.Store
+	window  time.Duration
+	maxWait time.Duration
+	pending map[string]*pendingMessage
+}
+
+type pendingMessage struct {
+	name     string
+	message  *api.Message
+	timer    *time.Timer
+	deadline time.Time
+}
+
+func NewDebouncer(next api.MessageHandler, store store.Store, window, maxWait time.Duration) *Debouncer {
+	return &Debouncer{
+		next:    next,
+		store:   store,
+		window:  window,
+		maxWait: maxWait,
+		pending: make(map[string]*pendingMessage),
+	}
+}
+
+func (d *Debouncer) Handle(ctx context.Context, name string, message *api.Message) {
+	if d.window <= 0 || !debouncedTypes[message.Type] {
+		d.next.Handle(ctx, name, message)
+		return
+	}
+
+	key := d.debounceKey(name, message)
+
+	d.Lock()
+	defer d.Unlock()
+
+	p, ok := d.pending[key]
+	if !ok {
+		p = &pendingMessage{}
+		if d.maxWait > 0 {
+			p.deadline = time.Now().Add(d.maxWait)
+		}
+		d.pending[key] = p
+		p.timer = time.AfterFunc(d.window, func() { d.fire(key, p) })
+	} else {
+		delay := d.window
+		if !p.deadline.IsZero() {
+			delay = min(delay, time.Until(p.deadline))
+		}
+		p.timer.Reset(delay)
+	}
+	p.name, p.message = name, message
+}
+
+func (d *Debouncer) fire(key string, p *pendingMessage) {
+	d.Lock()
+	if d.pending[key] != p {
+		// already passed on by an earlier run of the timer
+		d.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	d.Unlock()
+
+	d.next.Handle(context.Background(), p.name, p.message)
+}
+
+// Flush passes on all pending messages right away, e.g. on shutdown.
+func (d *Debouncer) Flush() {
+	d.Lock()
+	pending := d.pending
+	d.pending = make(map[string]*pendingMessage)
+	for _, p := range pending {
+		p.timer.Stop()
+	}
+	d.Unlock()
+
+	for _, p := range pending {
+		d.next.Handle(context.Background(), p.name, p.message)
+	}
+}
+
+// debounceKey keys message by the number of its room, so that a room
+// addressed by number and by name shares one burst. Names of rooms that are
+// not known yet, e.g. before the first poll, are used as they are.
+func (d *Debouncer) debounceKey(name string, message *api.Message) string {
+	room := message.RoomName
+	if room == "" {
+		room = strconv.Itoa(message.Room)
+	} else if device := d.store.GetDevice(name); device != nil && device.HeatAreas != nil {
+		for _, h := range *device.HeatAreas {
+			if h.Nr != nil && h.Name != nil && api.Slug(*h.Name) == room {
+				room = strconv.Itoa(*h.Nr)
+				break
+			}
+		}
+	}
+	return name + "/" + room + "/" + message.Type
+}
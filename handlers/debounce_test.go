@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingHandler struct {
+	sync.Mutex
+	messages []*api.Message
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, name string, message *api.Message) {
+	h.Lock()
+	defer h.Unlock()
+	h.messages = append(h.messages, message)
+}
+
+func (h *recordingHandler) handled() []*api.Message {
+	h.Lock()
+	defer h.Unlock()
+	return append([]*api.Message(nil), h.messages...)
+}
+
+func TestDebouncer_PassesOnLastMessageOfBurst(t *testing.T) {
+	next := &recordingHandler{}
+	d := NewDebouncer(next, store.NewInMemoryStore(), 50*time.Millisecond, time.Second)
+
+	for _, v := range []string{"21.0", "21.5", "22.0"} {
+		d.Handle(context.Background(), "eg", &api.Message{Room: 1, Type: "temperature_target", Data: v})
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Empty(t, next.handled())
+
+	require.Eventually(t, func() bool { return len(next.handled()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "22.0", next.handled()[0].Data)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, next.handled(), 1)
+}
+
+func TestDebouncer_KeysByDeviceRoomAndType(t *testing.T) {
+	next := &recordingHandler{}
+	d := NewDebouncer(next, store.NewInMemoryStore(), 30*time.Millisecond, time.Second)
+
+	d.Handle(context.Background(), "eg", &api.Message{Room: 1, Type: "temperature_target", Data: "21"})
+	d.Handle(context.Background(), "eg", &api.Message{Room: 2, Type: "temperature_target", Data: "22"})
+	d.Handle(context.Background(), "eg", &api.Message{RoomName: "bedroom", Type: "temperature_target", Data: "23"})
+	d.Handle(context.Background(), "eg", &api.Message{Room: 1, Type: "temperature_heat_day", Data: "21"})
+	d.Handle(context.Background(), "og", &api.Message{Room: 1, Type: "temperature_target", Data: "24"})
+
+	require.Eventually(t, func() bool { return len(next.handled()) == 5 }, time.Second, 10*time.Millisecond)
+}
+
+func TestDebouncer_KeysRoomByNumberAndNameAlike(t *testing.T) {
+	client := mock.NewMockClient()
+	router, _ := newTestRouter(t, client)
+	next := &recordingHandler{}
+	d := NewDebouncer(next, router.store, time.Hour, 0)
+
+	d.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "temperature_target", Data: "21"})
+	d.Handle(context.Background(), "device1", &api.Message{RoomName: "living_room", Type: "temperature_target", Data: "22"})
+	d.Flush()
+
+	require.Len(t, next.handled(), 1)
+	assert.Equal(t, "22", next.handled()[0].Data)
+}
+
+func TestDebouncer_MaxWait(t *testing.T) {
+	next := &recordingHandler{}
+	d := NewDebouncer(next, store.NewInMemoryStore(), 50*time.Millisecond, 100*time.Millisecond)
+
+	// a continuous stream never leaves a quiet period
+	for i := range 20 {
+		d.Handle(context.Background(), "eg", &api.Message{Room: 1, Type: "temperature_target", Data: api.FormatFloat(float64(i))})
+		time.Sleep(15 * time.Millisecond)
+	}
+
+	assert.GreaterOrEqual(t, len(next.handled()), 2)
+}
+
+func TestDebouncer_Disabled(t *testing.T) {
+	next := &recordingHandler{}
+	d := NewDebouncer(next, store.NewInMemoryStore(), 0, 0)
+
+	d.Handle(context.Background(), "eg", &api.Message{Room: 1, Type: "temperature_target", Data: "21"})
+	d.Handle(context.Background(), "eg", &api.Message{Room: 1, Type: "temperature_target", Data: "22"})
+
+	assert.Len(t, next.handled(), 2)
+}
+
+func TestDebouncer_Flush(t *testing.T) {
+	next := &recordingHandler{}
+	d := NewDebouncer(next, store.NewInMemoryStore(), time.Hour, 0)
+
+	d.Handle(context.Background(), "eg", &api.Message{Room: 1, Type: "temperature_target", Data: "21"})
+	d.Handle(context.Background(), "eg", &api.Message{Room: 1, Type: "temperature_target", Data: "22"})
+	d.Handle(context.Background(), "eg", &api.Message{Room: 2, Type: "temperature_target", Data: "23"})
+	assert.Empty(t, next.handled())
+
+	d.Flush()
+
+	require.Len(t, next.handled(), 2)
+	d.Flush()
+	assert.Len(t, next.handled(), 2)
+}
+
+func TestDebouncer_PassesOnPartialCommandsRightAway(t *testing.T) {
+	next := &recordingHandler{}
+	d := NewDebouncer(next, store.NewInMemoryStore(), time.Hour, time.Hour)
+
+	d.Handle(context.Background(), "eg", &api.Message{Room: api.DeviceRoom, Type: "vacation", Data: `{"start":"2025-12-24T18:00"}`})
+	d.Handle(context.Background(), "eg", &api.Message{Room: api.DeviceRoom, Type: "vacation", Data: `{"end":"2026-01-06T12:00"}`})
+
+	require.Len(t, next.handled(), 2)
+}
+
+func TestDebouncer_AppliesPartialVacationsOfOneWindow(t *testing.T) {
+	client := mock.NewMockClient()
+	router, _ := newTestRouter(t, client)
+	d := NewDebouncer(router, router.store, 500*time.Millisecond, 2*time.Second)
+
+	d.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "vacation", Data: `{"start":"2025-12-24T18:00"}`})
+	d.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "vacation", Data: `{"end":"2026-01-06T12:00"}`})
+	d.Flush()
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, "24.12.2025", *res.Device.Vacation.StartDate)
+	assert.Equal(t, "18:00", *res.Device.Vacation.StartTime)
+	assert.Equal(t, "06.01.2026", *res.Device.Vacation.EndDate)
+	assert.Equal(t, "12:00", *res.Device.Vacation.EndTime)
+}
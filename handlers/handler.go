@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/chrishrb/ezr2mqtt/api"
 	"github.com/chrishrb/ezr2mqtt/store"
@@ -11,52 +15,253 @@ import (
 )
 
 type HandlerRouter struct {
-	client  map[string]transport.Client
-	emitter api.Emitter
-	store   store.Store
+	client     map[string]transport.Client
+	emitter    api.Emitter
+	store      store.Store
+	limits     map[string]Limits
+	installer  map[string]bool
+	refreshers map[string]Refresher
+
+	// closed rejects messages once draining started, so that inflight is not
+	// added to while it is waited for
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+// Refresher fetches and publishes the state of a device out of band, e.g.
+// polling.Poller.
+type Refresher interface {
+	// Refresh publishes the state of the given rooms, all rooms if none
+	// are given. It must not block.
+	Refresh(rooms ...int)
+}
+
+type Opt func(*HandlerRouter)
+
+// WithLimits restricts the temperature targets accepted for the device.
+func WithLimits(name string, limits Limits) Opt {
+	return func(s *HandlerRouter) {
+		s.limits[name] = limits
+	}
 }
 
-func NewHandlerRouter(client map[string]transport.Client, emitter api.Emitter, store store.Store) *HandlerRouter {
-	return &HandlerRouter{
-		client:  client,
-		emitter: emitter,
-		store:   store,
+// WithInstallerSettings allows changing the installer settings of the
+// device, e.g. antifreeze and smart start.
+func WithInstallerSettings(name string) Opt {
+	return func(s *HandlerRouter) {
+		s.installer[name] = true
 	}
 }
 
+// WithRefresher refreshes the state of the device after each change.
+func WithRefresher(name string, refresher Refresher) Opt {
+	return func(s *HandlerRouter) {
+		s.refreshers[name] = refresher
+	}
+}
+
+func NewHandlerRouter(client map[string]transport.Client, emitter api.Emitter, store store.Store, opts ...Opt) *HandlerRouter {
+	s := &HandlerRouter{
+		client:     client,
+		emitter:    emitter,
+		store:      store,
+		limits:     make(map[string]Limits),
+		installer:  make(map[string]bool),
+		refreshers: make(map[string]Refresher),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Handle applies message to the addressed rooms. name and the room of the
+// message may be api.All, in which case the message is expanded to every
+// device and room. Each device receives a single change with all its rooms
+// and errors of all devices are reported together.
 func (s *HandlerRouter) Handle(ctx context.Context, name string, message *api.Message) {
-	var err error
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		slog.Warn("dropping message received while shutting down", "device_name", name, "message_type", message.Type)
+		return
+	}
+	s.inflight.Add(1)
+	s.mu.Unlock()
+	defer s.inflight.Done()
+
+	if nameTypes[message.Type] && (name == api.All || message.RoomName == api.All) {
+		slog.Error("error handling message", "error", "names can't be broadcast", "device_name", name, "message_type", message.Type)
+		return
+	}
+
+	names := []string{name}
+	if name == api.All {
+		names = make([]string, 0, len(s.client))
+		for n := range s.client {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+	}
+
+	var errs []error
+	for _, n := range names {
+		err := s.handleDevice(ctx, n, message)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		slog.Error("error handling message", "error", err, "device_name", name, "message_type", message.Type)
+	} else if len(names) > 1 || message.RoomName == api.All {
+		slog.Info("broadcast message handled", "device_name", name, "devices", len(names), "message_type", message.Type)
+	}
+}
+
+// Wait stops accepting messages and blocks until all messages being handled
+// are done.
+func (s *HandlerRouter) Wait() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.inflight.Wait()
+}
 
+func (s *HandlerRouter) handleDevice(ctx context.Context, name string, message *api.Message) error {
 	client, ok := s.client[name]
 	if !ok {
-		slog.Error("no transport client found for device", "device_name", name)
-		return
+		return errors.New("no transport client found for device")
 	}
 
 	id := s.store.GetID(name)
 	if id == nil {
-		slog.Error("no periodic store ID found for device", "device_name", name)
-		return
+		return errors.New("device not yet discovered, it is retried until the device is reachable")
 	}
 
-	err = s.route(client, *id, message)
+	resolve := resolveRooms
+	if deviceTypes[message.Type] {
+		resolve = resolveDevice
+	}
+	targets, err := resolve(s.store.GetDevice(name), message)
 	if err != nil {
-		slog.Error("error handling message", "error", err, "device_name", name, "message_type", message.Type)
+		return err
 	}
 
-	err = s.emitter.Emit(ctx, name, message)
+	err = s.route(client, name, *id, targets...)
 	if err != nil {
-		slog.Error("error emitting message", "type", message.Type, "error", err)
+		return err
+	}
+
+	rooms := make([]int, 0, len(targets))
+	for _, t := range targets {
+		err = s.emitter.Emit(ctx, name, t.message)
+		if err != nil {
+			slog.Error("error emitting message", "type", t.message.Type, "error", err)
+		}
+		rooms = append(rooms, t.message.Room)
+	}
+
+	if refresher, ok := s.refreshers[name]; ok {
+		refresher.Refresh(rooms...)
+	}
+	return nil
+}
+
+// route sends the changes of all targets to the device in one request.
+func (s *HandlerRouter) route(client transport.Client, name string, id string, targets ...target) error {
+	change := transport.Device{ID: &id}
+	heatAreas := make([]transport.HeatArea, 0, len(targets))
+	for _, t := range targets {
+		var err error
+		if deviceTypes[t.message.Type] {
+			err = s.routeDevice(t, name, &change)
+		} else {
+			heatArea := transport.HeatArea{Nr: &t.message.Room}
+			err = s.routeHeatArea(t, name, &heatArea)
+			heatAreas = append(heatAreas, heatArea)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(heatAreas) > 0 {
+		change.HeatAreas = &heatAreas
+	}
+
+	err := client.Send(&transport.Message{Device: change})
+	if err != nil {
+		return fmt.Errorf("error sending %s: %w", targets[0].message.Type, err)
+	}
+	return nil
+}
+
+func (s *HandlerRouter) routeDevice(t target, name string, change *transport.Device) error {
+	if installerTypes[t.message.Type] {
+		if !s.installer[name] {
+			return fmt.Errorf("%s is an installer setting, enable installer_settings to change it", t.message.Type)
+		}
+		return setInstallerSetting(t, s.limits[name], change)
+	}
+
+	switch t.message.Type {
+	case "vacation":
+		return setVacation(t, s.limits[name], change)
+	case "programs":
+		return setPrograms(t, change)
+	case "clock_sync":
+		return setClock(t, time.Now(), change)
+	case "cooling":
+		return setCooling(t, change)
+	case "changeover":
+		return setChangeover(t, change)
+	case "summer_winter":
+		return setSummerWinter(t, change)
+	case "device_mode":
+		return setDeviceMode(t, change)
+	case "changeover_mode":
+		return setChangeoverMode(t, change)
+	case "device_name":
+		return setDeviceName(t, change)
+	default:
+		return fmt.Errorf("unknown message type: %s", t.message.Type)
 	}
 }
 
-func (s *HandlerRouter) route(client transport.Client, id string, message *api.Message) error {
-	switch message.Type {
+func (s *HandlerRouter) routeHeatArea(t target, name string, change *transport.HeatArea) error {
+	switch t.message.Type {
 	case "temperature_target":
-		return setTemperatureTarget(client, id, message)
+		return setTemperatureTarget(t, s.limits[name], change)
+	case "temperature_heat_day":
+		return setTemperature(t, s.limits[name], &change.THeatDay)
+	case "temperature_heat_night":
+		return setTemperature(t, s.limits[name], &change.THeatNight)
+	case "temperature_cool_day":
+		return setTemperature(t, s.limits[name], &change.TCoolDay)
+	case "temperature_cool_night":
+		return setTemperature(t, s.limits[name], &change.TCoolNight)
+	case "temperature_floor_day":
+		return setTemperature(t, s.limits[name], &change.TFloorDay)
+	case "temperature_offset":
+		return setOffset(t, change)
+	case "temperature_reference":
+		return calibrateOffset(t, change)
 	case "heatarea_mode":
-		return setHeatareaMode(client, id, message)
+		return setHeatareaMode(t, change)
+	case "party":
+		return setParty(t, change)
+	case "presence":
+		return setPresence(t, change)
+	case "child_lock":
+		return setChildLock(t, change)
+	case "room_name":
+		return setRoomName(t, change)
+	case "program_week":
+		return setProgramWeek(t, change)
+	case "program_weekend":
+		return setProgramWeekend(t, change)
 	default:
-		return fmt.Errorf("unknown message type: %s", message.Type)
+		return fmt.Errorf("unknown message type: %s", t.message.Type)
 	}
 }
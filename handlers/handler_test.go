@@ -2,23 +2,105 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/chrishrb/ezr2mqtt/api"
 	"github.com/chrishrb/ezr2mqtt/store"
 	"github.com/chrishrb/ezr2mqtt/transport"
 	"github.com/chrishrb/ezr2mqtt/transport/mock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+type fakeEmitter struct {
+	sync.Mutex
+	messages []*api.Message
+}
+
+func (e *fakeEmitter) Emit(ctx context.Context, name string, message *api.Message) error {
+	e.Lock()
+	defer e.Unlock()
+	e.messages = append(e.messages, message)
+	return nil
+}
+
+func (e *fakeEmitter) EmitHADiscovery(ctx context.Context, component api.HAComponent, message api.HASensorDiscovery) error {
+	return nil
+}
+
+func (e *fakeEmitter) RemoveHADiscovery(ctx context.Context, component api.HAComponent, uniqueID string) error {
+	return nil
+}
+
+func (e *fakeEmitter) StateTopic(name string, message *api.Message) string {
+	return fmt.Sprintf("ezr/%s/%d/state/%s", name, message.Room, message.Type)
+}
+
+func (e *fakeEmitter) CommandTopic(name string, message *api.Message) string {
+	return fmt.Sprintf("ezr/%s/%d/set/%s", name, message.Room, message.Type)
+}
+
+// countingClient counts the requests sent to the wrapped client
+type countingClient struct {
+	transport.Client
+	sent int
+}
+
+func (c *countingClient) Send(msg *transport.Message) error {
+	c.sent++
+	return c.Client.Send(msg)
+}
+
+type fakeRefresher struct {
+	sync.Mutex
+	rooms [][]int
+}
+
+func (r *fakeRefresher) Refresh(rooms ...int) {
+	r.Lock()
+	defer r.Unlock()
+	r.rooms = append(r.rooms, rooms)
+}
+
+func newTestRouter(t *testing.T, client transport.Client, opts ...Opt) (*HandlerRouter, *fakeEmitter) {
+	t.Helper()
+
+	s := store.NewInMemoryStore()
+	res, err := client.Connect()
+	require.NoError(t, err)
+	s.SetID("device1", *res.Device.ID)
+	s.SetDevice("device1", &res.Device)
+
+	emitter := &fakeEmitter{}
+	return NewHandlerRouter(map[string]transport.Client{"device1": client}, emitter, s, opts...), emitter
+}
+
+func heatArea(t *testing.T, client transport.Client, nr int) transport.HeatArea {
+	t.Helper()
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	for _, h := range *res.Device.HeatAreas {
+		if *h.Nr == nr {
+			return h
+		}
+	}
+	require.Failf(t, "heat area not found", "heat area %d", nr)
+	return transport.HeatArea{}
+}
+
 func TestNewHandlerRouter(t *testing.T) {
 	client := mock.NewMockClient()
 	store := store.NewInMemoryStore()
+	emitter := &fakeEmitter{}
 	clientMap := map[string]transport.Client{
 		"device1": client,
 	}
 
-	router := NewHandlerRouter(clientMap, store)
+	router := NewHandlerRouter(clientMap, emitter, store)
 
 	assert.NotNil(t, router)
 	assert.Equal(t, clientMap, router.client)
@@ -27,50 +109,146 @@ func TestNewHandlerRouter(t *testing.T) {
 
 func TestHandlerRouter_Handle_Success(t *testing.T) {
 	client := mock.NewMockClient()
-	store := store.NewInMemoryStore()
-	deviceName := "device1"
-	deviceID := "DEVICE-123"
-
-	// Setup store with device ID
-	store.SetID(deviceName, deviceID)
-
-	clientMap := map[string]transport.Client{
-		deviceName: client,
-	}
-
-	router := NewHandlerRouter(clientMap, store)
+	router, emitter := newTestRouter(t, client)
 
 	msg := &api.Message{
 		Room: 1,
 		Type: "temperature_target",
 		Data: "22.5",
 	}
+	router.Handle(context.Background(), "device1", msg)
 
-	ctx := context.Background()
-	router.Handle(ctx, deviceName, msg)
+	assert.Equal(t, 22.5, *heatArea(t, client, 1).TTarget)
+	assert.Len(t, emitter.messages, 1)
+	assert.Equal(t, "living_room", emitter.messages[0].RoomName)
+}
 
-	// Verify that the message was sent to the client
-	result, err := client.Connect()
-	assert.NoError(t, err)
-	assert.NotNil(t, result)
-
-	// Verify the temperature was set correctly
-	found := false
-	for _, heatArea := range result.Device.HeatAreas {
-		if heatArea.Nr == 1 {
-			assert.Equal(t, 22.5, heatArea.TTarget)
-			found = true
-			break
-		}
+func TestHandlerRouter_Handle_RoomByName(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	msg := &api.Message{
+		RoomName: "bedroom",
+		Type:     "temperature_target",
+		Data:     "21",
+	}
+	router.Handle(context.Background(), "device1", msg)
+
+	assert.Equal(t, 21.0, *heatArea(t, client, 2).TTarget)
+	assert.Equal(t, 22.0, *heatArea(t, client, 1).TTarget)
+	require.Len(t, emitter.messages, 1)
+	assert.Equal(t, 2, emitter.messages[0].Room)
+}
+
+func TestHandlerRouter_Handle_UnknownRoom(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *api.Message
+	}{
+		{"unknown name", &api.Message{RoomName: "kitchen", Type: "temperature_target", Data: "21"}},
+		{"unknown number", &api.Message{Room: 7, Type: "temperature_target", Data: "21"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mock.NewMockClient()
+			router, emitter := newTestRouter(t, client)
+
+			router.Handle(context.Background(), "device1", tt.msg)
+
+			assert.Equal(t, 22.0, *heatArea(t, client, 1).TTarget)
+			assert.Equal(t, 20.0, *heatArea(t, client, 2).TTarget)
+			assert.Empty(t, emitter.messages)
+		})
 	}
-	assert.True(t, found, "Heat area 1 should exist")
+}
+
+func TestHandlerRouter_Handle_RoomByNameBeforeFirstPoll(t *testing.T) {
+	client := mock.NewMockClient()
+	store := store.NewInMemoryStore()
+	store.SetID("device1", "MOCK-12345")
+	emitter := &fakeEmitter{}
+	router := NewHandlerRouter(map[string]transport.Client{"device1": client}, emitter, store)
+
+	router.Handle(context.Background(), "device1", &api.Message{
+		RoomName: "bedroom",
+		Type:     "temperature_target",
+		Data:     "21",
+	})
+
+	assert.Equal(t, 20.0, *heatArea(t, client, 2).TTarget)
+	assert.Empty(t, emitter.messages)
+}
+
+func TestHandlerRouter_Handle_AllRooms(t *testing.T) {
+	client := &countingClient{Client: mock.NewMockClient()}
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{
+		RoomName: api.All,
+		Type:     "heatarea_mode",
+		Data:     "night",
+	})
+
+	assert.Equal(t, 1, client.sent)
+	assert.Equal(t, 2, *heatArea(t, client, 1).Mode)
+	assert.Equal(t, 2, *heatArea(t, client, 2).Mode)
+	require.Len(t, emitter.messages, 2)
+	assert.Equal(t, "living_room", emitter.messages[0].RoomName)
+	assert.Equal(t, "bedroom", emitter.messages[1].RoomName)
+}
+
+func TestHandlerRouter_Handle_AllDevices(t *testing.T) {
+	s := store.NewInMemoryStore()
+	clients := map[string]transport.Client{}
+	for _, name := range []string{"eg", "og"} {
+		client := &countingClient{Client: mock.NewMockClient()}
+		res, err := client.Connect()
+		require.NoError(t, err)
+		s.SetID(name, *res.Device.ID)
+		s.SetDevice(name, &res.Device)
+		clients[name] = client
+	}
+	// a device that was not polled yet doesn't stop the others
+	clients["ug"] = &countingClient{Client: mock.NewMockClient()}
+	emitter := &fakeEmitter{}
+	router := NewHandlerRouter(clients, emitter, s)
+
+	router.Handle(context.Background(), api.All, &api.Message{
+		RoomName: api.All,
+		Type:     "temperature_target",
+		Data:     "18",
+	})
+
+	for _, name := range []string{"eg", "og"} {
+		client := clients[name].(*countingClient)
+		assert.Equal(t, 1, client.sent, name)
+		assert.Equal(t, 18.0, *heatArea(t, client, 1).TTarget, name)
+		assert.Equal(t, 18.0, *heatArea(t, client, 2).TTarget, name)
+	}
+	assert.Equal(t, 0, clients["ug"].(*countingClient).sent)
+	assert.Len(t, emitter.messages, 4)
+}
+
+func TestHandlerRouter_Handle_AllRoomsInvalidValue(t *testing.T) {
+	client := &countingClient{Client: mock.NewMockClient()}
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{
+		RoomName: api.All,
+		Type:     "heatarea_mode",
+		Data:     "party",
+	})
+
+	assert.Equal(t, 0, client.sent)
+	assert.Empty(t, emitter.messages)
 }
 
 func TestHandlerRouter_Handle_NoClient(t *testing.T) {
 	store := store.NewInMemoryStore()
 	clientMap := map[string]transport.Client{}
 
-	router := NewHandlerRouter(clientMap, store)
+	router := NewHandlerRouter(clientMap, &fakeEmitter{}, store)
 
 	msg := &api.Message{
 		Room: 1,
@@ -93,7 +271,7 @@ func TestHandlerRouter_Handle_NoStoreID(t *testing.T) {
 		deviceName: client,
 	}
 
-	router := NewHandlerRouter(clientMap, store)
+	router := NewHandlerRouter(clientMap, &fakeEmitter{}, store)
 
 	msg := &api.Message{
 		Room: 1,
@@ -108,18 +286,7 @@ func TestHandlerRouter_Handle_NoStoreID(t *testing.T) {
 
 func TestHandlerRouter_Handle_UnknownMessageType(t *testing.T) {
 	client := mock.NewMockClient()
-	store := store.NewInMemoryStore()
-	deviceName := "device1"
-	deviceID := "DEVICE-123"
-
-	// Setup store with device ID
-	store.SetID(deviceName, deviceID)
-
-	clientMap := map[string]transport.Client{
-		deviceName: client,
-	}
-
-	router := NewHandlerRouter(clientMap, store)
+	router, _ := newTestRouter(t, client)
 
 	msg := &api.Message{
 		Room: 1,
@@ -129,7 +296,7 @@ func TestHandlerRouter_Handle_UnknownMessageType(t *testing.T) {
 
 	ctx := context.Background()
 	// Should not panic, just log error
-	router.Handle(ctx, deviceName, msg)
+	router.Handle(ctx, "device1", msg)
 }
 
 func TestHandlerRouter_Route_TemperatureTarget(t *testing.T) {
@@ -137,7 +304,7 @@ func TestHandlerRouter_Route_TemperatureTarget(t *testing.T) {
 	store := store.NewInMemoryStore()
 	deviceID := "DEVICE-123"
 
-	router := NewHandlerRouter(map[string]transport.Client{}, store)
+	router := NewHandlerRouter(map[string]transport.Client{}, &fakeEmitter{}, store)
 
 	msg := &api.Message{
 		Room: 2,
@@ -145,20 +312,11 @@ func TestHandlerRouter_Route_TemperatureTarget(t *testing.T) {
 		Data: "23.0",
 	}
 
-	err := router.route(client, deviceID, msg)
+	h := heatArea(t, client, 2)
+	err := router.route(client, "device1", deviceID, target{message: msg, heatArea: &h})
 	assert.NoError(t, err)
 
-	// Verify the message was sent
-	result, _ := client.Connect()
-	found := false
-	for _, heatArea := range result.Device.HeatAreas {
-		if heatArea.Nr == 2 {
-			assert.Equal(t, 23.0, heatArea.TTarget)
-			found = true
-			break
-		}
-	}
-	assert.True(t, found, "Heat area 2 should have updated temperature")
+	assert.Equal(t, 23.0, *heatArea(t, client, 2).TTarget)
 }
 
 func TestHandlerRouter_Route_HeatareaMode(t *testing.T) {
@@ -166,7 +324,7 @@ func TestHandlerRouter_Route_HeatareaMode(t *testing.T) {
 	store := store.NewInMemoryStore()
 	deviceID := "DEVICE-123"
 
-	router := NewHandlerRouter(map[string]transport.Client{}, store)
+	router := NewHandlerRouter(map[string]transport.Client{}, &fakeEmitter{}, store)
 
 	tests := []struct {
 		name         string
@@ -186,20 +344,10 @@ func TestHandlerRouter_Route_HeatareaMode(t *testing.T) {
 				Data: tt.data,
 			}
 
-			err := router.route(client, deviceID, msg)
+			err := router.route(client, "device1", deviceID, target{message: msg})
 			assert.NoError(t, err)
 
-			// Verify the message was sent
-			result, _ := client.Connect()
-			found := false
-			for _, heatArea := range result.Device.HeatAreas {
-				if heatArea.Nr == 2 {
-					assert.Equal(t, tt.expectedMode, heatArea.Mode)
-					found = true
-					break
-				}
-			}
-			assert.True(t, found, "Heat area 2 should have updated mode")
+			assert.Equal(t, tt.expectedMode, *heatArea(t, client, 2).Mode)
 		})
 	}
 }
@@ -209,7 +357,7 @@ func TestHandlerRouter_Route_UnknownType(t *testing.T) {
 	store := store.NewInMemoryStore()
 	deviceID := "DEVICE-123"
 
-	router := NewHandlerRouter(map[string]transport.Client{}, store)
+	router := NewHandlerRouter(map[string]transport.Client{}, &fakeEmitter{}, store)
 
 	msg := &api.Message{
 		Room: 1,
@@ -217,7 +365,158 @@ func TestHandlerRouter_Route_UnknownType(t *testing.T) {
 		Data: "data",
 	}
 
-	err := router.route(client, deviceID, msg)
+	err := router.route(client, "device1", deviceID, target{message: msg})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "unknown message type")
 }
+
+func TestHandlerRouter_Handle_TemperatureTargetValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   Limits
+		room     int
+		data     string
+		expected float64
+		emitted  string
+	}{
+		{"valid", Limits{}, 1, "23", 23.0, "23.00"},
+		{"rounded to default step", Limits{}, 1, "22.3", 22.5, "22.50"},
+		{"rounded to device step", Limits{TemperatureStep: 0.2}, 1, "22.35", 22.4, "22.40"},
+		{"controller minimum", Limits{}, 1, "5", 5.0, "5.00"},
+		{"controller maximum", Limits{}, 1, "30", 30.0, "30.00"},
+		{"below controller minimum", Limits{}, 1, "4.5", 22.0, ""},
+		{"above controller maximum", Limits{}, 1, "99", 22.0, ""},
+		{"negative", Limits{}, 1, "-3", 22.0, ""},
+		{"not a number", Limits{}, 1, "NaN", 22.0, ""},
+		{"infinite", Limits{}, 1, "+Inf", 22.0, ""},
+		{"configured maximum by name", Limits{Rooms: map[string]Bounds{"living_room": {Max: ptr(22.0)}}}, 1, "22.5", 22.0, ""},
+		{"configured minimum by number", Limits{Rooms: map[string]Bounds{"1": {Min: ptr(18.0)}}}, 1, "17", 22.0, ""},
+		{"configured bounds of other room", Limits{Rooms: map[string]Bounds{"bedroom": {Max: ptr(20.0)}}}, 1, "24", 24.0, "24.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mock.NewMockClient()
+			router, emitter := newTestRouter(t, client, WithLimits("device1", tt.limits))
+
+			router.Handle(context.Background(), "device1", &api.Message{
+				Room: tt.room,
+				Type: "temperature_target",
+				Data: tt.data,
+			})
+
+			assert.Equal(t, tt.expected, *heatArea(t, client, tt.room).TTarget)
+			if tt.emitted == "" {
+				assert.Empty(t, emitter.messages)
+			} else {
+				require.Len(t, emitter.messages, 1)
+				assert.Equal(t, tt.emitted, emitter.messages[0].Data)
+			}
+		})
+	}
+}
+
+func TestHandlerRouter_Handle_TemperatureTargetBeforeFirstPoll(t *testing.T) {
+	client := mock.NewMockClient()
+	store := store.NewInMemoryStore()
+	store.SetID("device1", "MOCK-12345")
+	emitter := &fakeEmitter{}
+	router := NewHandlerRouter(map[string]transport.Client{"device1": client}, emitter, store)
+
+	router.Handle(context.Background(), "device1", &api.Message{
+		Room: 1,
+		Type: "temperature_target",
+		Data: "21",
+	})
+
+	assert.Equal(t, 22.0, *heatArea(t, client, 1).TTarget)
+	assert.Empty(t, emitter.messages)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestHandlerRouter_Handle_RefreshesAfterChange(t *testing.T) {
+	client := mock.NewMockClient()
+	s := store.NewInMemoryStore()
+	res, err := client.Connect()
+	require.NoError(t, err)
+	s.SetID("device1", *res.Device.ID)
+	s.SetDevice("device1", &res.Device)
+	refresher := &fakeRefresher{}
+	router := NewHandlerRouter(map[string]transport.Client{"device1": client}, &fakeEmitter{}, s, WithRefresher("device1", refresher))
+
+	router.Handle(context.Background(), "device1", &api.Message{RoomName: api.All, Type: "heatarea_mode", Data: "night"})
+	assert.Equal(t, [][]int{{1, 2}}, refresher.rooms)
+
+	// failed changes don't refresh
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "heatarea_mode", Data: "party"})
+	assert.Len(t, refresher.rooms, 1)
+}
+
+func TestHandlerRouter_Handle_Setpoints(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	for _, typ := range []string{"temperature_heat_day", "temperature_heat_night", "temperature_cool_day", "temperature_cool_night", "temperature_floor_day"} {
+		router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: typ, Data: "21.3"})
+	}
+
+	h := heatArea(t, client, 1)
+	assert.Equal(t, 21.5, *h.THeatDay)
+	assert.Equal(t, 21.5, *h.THeatNight)
+	assert.Equal(t, 21.5, *h.TCoolDay)
+	assert.Equal(t, 21.5, *h.TCoolNight)
+	assert.Equal(t, 21.5, *h.TFloorDay)
+	require.Len(t, emitter.messages, 5)
+	assert.Equal(t, "21.50", emitter.messages[0].Data)
+
+	// validated like the temperature target
+	counting := &countingClient{Client: client}
+	router, _ = newTestRouter(t, counting)
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "temperature_heat_day", Data: "31"})
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "temperature_cool_night", Data: "NaN"})
+	assert.Zero(t, counting.sent)
+}
+
+// blockingClient blocks requests until release is closed
+type blockingClient struct {
+	transport.Client
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingClient) Send(msg *transport.Message) error {
+	close(c.started)
+	<-c.release
+	return c.Client.Send(msg)
+}
+
+func TestHandlerRouter_Wait(t *testing.T) {
+	client := &blockingClient{Client: mock.NewMockClient(), started: make(chan struct{}), release: make(chan struct{})}
+	router, _ := newTestRouter(t, client)
+
+	go router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "heatarea_mode", Data: "night"})
+	<-client.started
+
+	waited := make(chan struct{})
+	go func() {
+		router.Wait()
+		close(waited)
+	}()
+
+	// the message in flight is waited for
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while a message was handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(client.release)
+	<-waited
+	assert.Equal(t, 2, *heatArea(t, client, 1).Mode)
+
+	// messages arriving after draining started are rejected
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "heatarea_mode", Data: "day"})
+	assert.Equal(t, 2, *heatArea(t, client, 1).Mode)
+}
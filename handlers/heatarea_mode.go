@@ -3,14 +3,13 @@ package handlers
 import (
 	"fmt"
 
-	"github.com/chrishrb/ezr2mqtt/api"
 	"github.com/chrishrb/ezr2mqtt/transport"
 )
 
-func setHeatareaMode(client transport.Client, id string, message *api.Message) error {
+func setHeatareaMode(t target, change *transport.HeatArea) error {
 	var mode int
 
-	switch message.Data {
+	switch t.message.Data {
 	case "auto":
 		mode = 0
 	case "day":
@@ -18,25 +17,9 @@ func setHeatareaMode(client transport.Client, id string, message *api.Message) e
 	case "night":
 		mode = 2
 	default:
-		return fmt.Errorf("unknown heatarea mode: %s", message.Data)
+		return fmt.Errorf("unknown heatarea mode: %s", t.message.Data)
 	}
 
-	msg := transport.Message{
-		Device: transport.Device{
-			ID: &id,
-			HeatAreas: &[]transport.HeatArea{{
-				Nr:   &message.Room,
-				Mode: &mode,
-			}},
-		},
-	}
-
-	err := client.Send(&msg)
-	if err != nil {
-		return fmt.Errorf("error sending heatarea mode: %w", err)
-	}
-
-	// TODO: send mqtt message to confirm change
-
+	change.Mode = &mode
 	return nil
 }
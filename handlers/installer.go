@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// installerTypes are the message types of installer settings, which are
+// only accepted for devices configured WithInstallerSettings.
+var installerTypes = map[string]bool{
+	"antifreeze":             true,
+	"antifreeze_temperature": true,
+	"eco_diff":               true,
+	"eco_input_mode":         true,
+	"smart_start":            true,
+	"first_open_time":        true,
+}
+
+// setInstallerSetting validates an installer setting and updates the
+// message with the normalized value.
+func setInstallerSetting(t target, limits Limits, change *transport.Device) error {
+	switch t.message.Type {
+	case "antifreeze":
+		return setFlag(t, &change.Antifreeze)
+	case "smart_start":
+		return setFlag(t, &change.SmartStart)
+	case "antifreeze_temperature":
+		return setSettingTemperature(t, limits, transport.MinAntifreezeTemp, transport.MaxAntifreezeTemp, &change.AntifreezeTemp)
+	case "eco_diff":
+		return setSettingTemperature(t, limits, 0, transport.MaxEcoDiff, &change.EcoDiff)
+	case "eco_input_mode":
+		mode, err := parseMode(t.message.Data)
+		if err != nil {
+			return fmt.Errorf("invalid eco input mode: %w", err)
+		}
+		change.EcoInputMode = &mode
+		return nil
+	case "first_open_time":
+		minutes, err := strconv.Atoi(t.message.Data)
+		if err != nil {
+			return fmt.Errorf("invalid first open time, expected minutes: %s", t.message.Data)
+		}
+		if minutes < 0 || minutes > transport.MaxFirstOpenTime {
+			return fmt.Errorf("first open time of %d minutes is out of range 0 to %d", minutes, transport.MaxFirstOpenTime)
+		}
+		change.FirstOpenTime = &minutes
+		return nil
+	default:
+		return fmt.Errorf("unknown message type: %s", t.message.Type)
+	}
+}
+
+func setFlag(t target, field **int) error {
+	v, err := parseOnOff(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("unknown %s value: %s", t.message.Type, t.message.Data)
+	}
+	*field = &v
+	return nil
+}
+
+// setSettingTemperature rounds a temperature to the temperature step and
+// checks it against the given range.
+func setSettingTemperature(t target, limits Limits, minimum, maximum float64, field **float64) error {
+	v, err := parseTemperature(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("invalid %s value: %v", t.message.Type, t.message.Data)
+	}
+	v = limits.round(v)
+	err = Bounds{Min: &minimum, Max: &maximum}.check(v, "accepted")
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", t.message.Type, err)
+	}
+
+	t.message.Data = api.FormatFloat(v)
+	*field = &v
+	return nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRouter_Handle_InstallerSettings(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client, WithInstallerSettings("device1"))
+
+	for _, m := range []*api.Message{
+		{Room: api.DeviceRoom, Type: "antifreeze", Data: "off"},
+		{Room: api.DeviceRoom, Type: "antifreeze_temperature", Data: "7.3"},
+		{Room: api.DeviceRoom, Type: "eco_diff", Data: "3"},
+		{Room: api.DeviceRoom, Type: "eco_input_mode", Data: "1"},
+		{Room: api.DeviceRoom, Type: "smart_start", Data: "on"},
+		{Room: api.DeviceRoom, Type: "first_open_time", Data: "15"},
+	} {
+		router.Handle(context.Background(), "device1", m)
+	}
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, 0, *res.Device.Antifreeze)
+	assert.Equal(t, 7.5, *res.Device.AntifreezeTemp)
+	assert.Equal(t, 3.0, *res.Device.EcoDiff)
+	assert.Equal(t, 1, *res.Device.EcoInputMode)
+	assert.Equal(t, 1, *res.Device.SmartStart)
+	assert.Equal(t, 15, *res.Device.FirstOpenTime)
+
+	require.Len(t, emitter.messages, 6)
+	assert.Equal(t, "7.50", emitter.messages[1].Data)
+}
+
+func TestHandlerRouter_Handle_InstallerSettingsDisabled(t *testing.T) {
+	client := &countingClient{Client: mock.NewMockClient()}
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "antifreeze", Data: "off"})
+
+	assert.Zero(t, client.sent)
+	assert.Empty(t, emitter.messages)
+}
+
+func TestHandlerRouter_Handle_InstallerSettingsValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  string
+		data string
+	}{
+		{name: "invalid antifreeze", typ: "antifreeze", data: "1"},
+		{name: "antifreeze temperature too high", typ: "antifreeze_temperature", data: "16"},
+		{name: "antifreeze temperature not a number", typ: "antifreeze_temperature", data: "Inf"},
+		{name: "negative eco diff", typ: "eco_diff", data: "-1"},
+		{name: "invalid eco input mode", typ: "eco_input_mode", data: "open"},
+		{name: "invalid smart start", typ: "smart_start", data: "true"},
+		{name: "first open time too long", typ: "first_open_time", data: "61"},
+		{name: "first open time not minutes", typ: "first_open_time", data: "1.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingClient{Client: mock.NewMockClient()}
+			router, emitter := newTestRouter(t, client, WithInstallerSettings("device1"))
+
+			router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: tt.typ, Data: tt.data})
+
+			assert.Zero(t, client.sent)
+			assert.Empty(t, emitter.messages)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// DefaultTemperatureStep is used if Limits.TemperatureStep is not set.
+const DefaultTemperatureStep = 0.5
+
+// Limits restricts the temperature targets accepted for a device in
+// addition to the limits reported by the controller.
+type Limits struct {
+	// TemperatureStep is the resolution of temperature targets, e.g. 0.2 or 0.5
+	TemperatureStep float64
+	// Rooms holds user defined bounds keyed by room number or room name
+	Rooms map[string]Bounds
+}
+
+// Bounds is an inclusive temperature range, nil means unbounded.
+type Bounds struct {
+	Min *float64
+	Max *float64
+}
+
+func (l Limits) step() float64 {
+	if l.TemperatureStep <= 0 {
+		return DefaultTemperatureStep
+	}
+	return l.TemperatureStep
+}
+
+// bounds returns the user defined bounds of the room, the room number takes
+// precedence over the name.
+func (l Limits) bounds(room int, roomName string) Bounds {
+	if b, ok := l.Rooms[strconv.Itoa(room)]; ok {
+		return b
+	}
+	return l.Rooms[roomName]
+}
+
+// round rounds v to the temperature step.
+func (l Limits) round(v float64) float64 {
+	step := l.step()
+	// round again to get rid of floating point noise, e.g. 21.400000000000002
+	return math.Round(math.Round(v/step)*step*100) / 100
+}
+
+// check returns an error if v is out of the range given by min and max.
+func (b Bounds) check(v float64, source string) error {
+	if b.Min != nil && v < *b.Min {
+		return fmt.Errorf("%s is below the %s minimum of %s", strconv.FormatFloat(v, 'f', -1, 64), source, strconv.FormatFloat(*b.Min, 'f', -1, 64))
+	}
+	if b.Max != nil && v > *b.Max {
+		return fmt.Errorf("%s is above the %s maximum of %s", strconv.FormatFloat(v, 'f', -1, 64), source, strconv.FormatFloat(*b.Max, 'f', -1, 64))
+	}
+	return nil
+}
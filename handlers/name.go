@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// nameTypes are the message types renaming rooms or devices. They can't be
+// broadcast, as that would give all of them the same name.
+var nameTypes = map[string]bool{
+	"room_name":   true,
+	"device_name": true,
+}
+
+// setRoomName renames a room. The name must be valid for the controller and
+// its slug must differ from the other rooms, so that the room can still be
+// addressed by name.
+func setRoomName(t target, change *transport.HeatArea) error {
+	name := strings.TrimSpace(t.message.Data)
+	err := checkName(name)
+	if err != nil {
+		return fmt.Errorf("invalid room name: %w", err)
+	}
+	if t.device == nil || t.heatArea == nil {
+		return errors.New("rooms are unknown as the device was not polled yet")
+	}
+
+	slug := api.Slug(name)
+	if slug == api.All {
+		return fmt.Errorf("invalid room name: %q is reserved for broadcasts", name)
+	}
+	for _, h := range *t.device.HeatAreas {
+		if h.Nr != nil && h.Name != nil && *h.Nr != t.message.Room && api.Slug(*h.Name) == slug {
+			return fmt.Errorf("invalid room name: room %d is named %q already", *h.Nr, *h.Name)
+		}
+	}
+
+	t.message.Data = name
+	change.Name = &name
+	return nil
+}
+
+func setDeviceName(t target, change *transport.Device) error {
+	name := strings.TrimSpace(t.message.Data)
+	err := checkName(name)
+	if err != nil {
+		return fmt.Errorf("invalid device name: %w", err)
+	}
+
+	t.message.Data = name
+	change.Name = &name
+	return nil
+}
+
+// checkName returns an error if the controller can't store or display the
+// name. It accepts printable Latin-1 characters, e.g. umlauts, except those
+// with a meaning in XML.
+func checkName(name string) error {
+	if name == "" {
+		return errors.New("name is empty")
+	}
+	if n := utf8.RuneCountInString(name); n > transport.MaxNameLength {
+		return fmt.Errorf("name has %d characters, at most %d are allowed", n, transport.MaxNameLength)
+	}
+	for _, r := range name {
+		if r < 0x20 || (r >= 0x7f && r < 0xa0) || r > 0xff || strings.ContainsRune(`<>&"'`, r) {
+			return fmt.Errorf("character %q is not allowed", r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRouter_Handle_RoomName(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{RoomName: "bedroom", Type: "room_name", Data: " Kinderzimmer Süd "})
+
+	assert.Equal(t, "Kinderzimmer Süd", *heatArea(t, client, 2).Name)
+	require.Len(t, emitter.messages, 1)
+	assert.Equal(t, 2, emitter.messages[0].Room)
+	assert.Equal(t, "Kinderzimmer Süd", emitter.messages[0].Data)
+
+	// a new spelling of its own name
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "room_name", Data: "LIVING ROOM"})
+	assert.Equal(t, "LIVING ROOM", *heatArea(t, client, 1).Name)
+}
+
+func TestHandlerRouter_Handle_DeviceName(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "device_name", Data: "Erdgeschoss"})
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, "Erdgeschoss", *res.Device.Name)
+	require.Len(t, emitter.messages, 1)
+}
+
+func TestHandlerRouter_Handle_NameValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		device   string
+		room     int
+		roomName string
+		typ      string
+		data     string
+	}{
+		{name: "empty", room: 1, typ: "room_name", data: "  "},
+		{name: "too long", room: 1, typ: "room_name", data: "Wohnzimmer mit Kamin und Essecke"},
+		{name: "not latin-1", room: 1, typ: "room_name", data: "Wohnzimmer 🔥"},
+		{name: "xml", room: 1, typ: "room_name", data: "<Bad>"},
+		{name: "control character", room: 1, typ: "room_name", data: "Bad\tOben"},
+		{name: "taken", room: 1, typ: "room_name", data: "bedroom"},
+		{name: "reserved", room: 1, typ: "room_name", data: "All"},
+		{name: "all rooms", roomName: api.All, typ: "room_name", data: "Room"},
+		{name: "device name too long", typ: "device_name", data: "Erdgeschoss und Keller!"},
+		{name: "all devices", device: api.All, typ: "device_name", data: "Haus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingClient{Client: mock.NewMockClient()}
+			router, emitter := newTestRouter(t, client)
+
+			device := "device1"
+			if tt.device != "" {
+				device = tt.device
+			}
+			router.Handle(context.Background(), device, &api.Message{Room: tt.room, RoomName: tt.roomName, Type: tt.typ, Data: tt.data})
+
+			assert.Zero(t, client.sent)
+			assert.Empty(t, emitter.messages)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// setOffset sets the correction of the measured room temperature. The
+// message is updated with the offset rounded to the step of the controller.
+func setOffset(t target, change *transport.HeatArea) error {
+	v, err := parseTemperature(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("invalid temperature offset value: %v", t.message.Data)
+	}
+	return applyOffset(t, v, change)
+}
+
+// calibrateOffset sets the offset so that the room reports the reference
+// temperature, e.g. measured by a separate thermometer. The message is
+// turned into the resulting temperature_offset.
+func calibrateOffset(t target, change *transport.HeatArea) error {
+	reference, err := parseTemperature(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("invalid temperature reference value: %v", t.message.Data)
+	}
+	if t.heatArea == nil || t.heatArea.TActual == nil {
+		return errors.New("room temperature is unknown as the device was not polled yet")
+	}
+
+	// the reported temperature includes the current offset
+	offset := reference - *t.heatArea.TActual
+	if t.heatArea.Offset != nil {
+		offset += *t.heatArea.Offset
+	}
+	t.message.Type = "temperature_offset"
+	return applyOffset(t, offset, change)
+}
+
+func applyOffset(t target, v float64, change *transport.HeatArea) error {
+	v = math.Round(v/transport.OffsetStep) * transport.OffsetStep
+	// get rid of floating point noise, e.g. 0.30000000000000004
+	v = math.Round(v*100) / 100
+
+	minimum, maximum := -transport.MaxOffset, transport.MaxOffset
+	err := Bounds{Min: &minimum, Max: &maximum}.check(v, "controller")
+	if err != nil {
+		return fmt.Errorf("invalid temperature offset for room %d: %w", t.message.Room, err)
+	}
+
+	t.message.Data = api.FormatFloat(v)
+	change.Offset = &v
+	return nil
+}
+
+func parseTemperature(data string) (float64, error) {
+	v, err := strconv.ParseFloat(data, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("not a number: %v", v)
+	}
+	return v, nil
+}
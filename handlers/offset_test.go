@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRouter_Handle_TemperatureOffset(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "temperature_offset", Data: "-1.26"})
+
+	assert.Equal(t, -1.3, *heatArea(t, client, 1).Offset)
+	require.Len(t, emitter.messages, 1)
+	assert.Equal(t, "-1.30", emitter.messages[0].Data)
+}
+
+func TestHandlerRouter_Handle_TemperatureReference(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	// the bedroom reports 19.5 with an offset of -0.5
+	router.Handle(context.Background(), "device1", &api.Message{Room: 2, Type: "temperature_reference", Data: "18.8"})
+
+	assert.Equal(t, -1.2, *heatArea(t, client, 2).Offset)
+	require.Len(t, emitter.messages, 1)
+	assert.Equal(t, "temperature_offset", emitter.messages[0].Type)
+	assert.Equal(t, "-1.20", emitter.messages[0].Data)
+}
+
+func TestHandlerRouter_Handle_TemperatureOffsetValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  string
+		data string
+	}{
+		{name: "invalid offset", typ: "temperature_offset", data: "cold"},
+		{name: "offset not a number", typ: "temperature_offset", data: "NaN"},
+		{name: "offset too high", typ: "temperature_offset", data: "2.1"},
+		{name: "offset too low", typ: "temperature_offset", data: "-3"},
+		{name: "invalid reference", typ: "temperature_reference", data: ""},
+		{name: "reference too far off", typ: "temperature_reference", data: "30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingClient{Client: mock.NewMockClient()}
+			router, emitter := newTestRouter(t, client)
+
+			router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: tt.typ, Data: tt.data})
+
+			assert.Zero(t, client.sent)
+			assert.Empty(t, emitter.messages)
+		})
+	}
+}
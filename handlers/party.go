@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// setParty starts or ends the party mode. The payload is "on", "off" or the
+// duration of the party in minutes, 0 ends it. "on" keeps the duration
+// configured on the controller. The message is updated to the resulting
+// state.
+func setParty(t target, change *transport.HeatArea) error {
+	var party int
+
+	switch t.message.Data {
+	case "on":
+		party = 1
+	case "off":
+		party = 0
+	default:
+		minutes, err := strconv.Atoi(t.message.Data)
+		if err != nil {
+			return fmt.Errorf("invalid party value, expected on, off or minutes: %s", t.message.Data)
+		}
+		if minutes < 0 || minutes > transport.MaxPartyDuration {
+			return fmt.Errorf("party duration of %d minutes is out of range 0 to %d", minutes, transport.MaxPartyDuration)
+		}
+		if minutes > 0 {
+			party = 1
+			change.PartyRemainingTime = &minutes
+		}
+	}
+
+	t.message.Data = api.OnOff(party)
+	change.Party = &party
+	return nil
+}
+
+func setPresence(t target, change *transport.HeatArea) error {
+	presence, err := parseOnOff(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("unknown presence value: %s", t.message.Data)
+	}
+
+	change.Presence = &presence
+	return nil
+}
+
+// setChildLock locks or unlocks the thermostats of the room, if the
+// controller allows it for the room.
+func setChildLock(t target, change *transport.HeatArea) error {
+	if t.heatArea == nil {
+		return errors.New("child lock is unknown as the device was not polled yet")
+	}
+	if !t.heatArea.Lockable() {
+		return fmt.Errorf("child lock is not available in room %d", t.message.Room)
+	}
+
+	locked, err := parseOnOff(t.message.Data)
+	if err != nil {
+		return fmt.Errorf("unknown child lock value: %s", t.message.Data)
+	}
+
+	change.IsLocked = &locked
+	return nil
+}
+
+// parseOnOff converts "on" and "off" to the flags of the controller.
+func parseOnOff(data string) (int, error) {
+	switch data {
+	case "on":
+		return 1, nil
+	case "off":
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("expected on or off: %s", data)
+	}
+}
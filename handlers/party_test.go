@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRouter_Handle_Party(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "party", Data: "90"})
+
+	h := heatArea(t, client, 1)
+	assert.Equal(t, 1, *h.Party)
+	assert.Equal(t, 90, *h.PartyRemainingTime)
+	require.Len(t, emitter.messages, 1)
+	assert.Equal(t, "on", emitter.messages[0].Data)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "party", Data: "0"})
+	assert.Equal(t, 0, *heatArea(t, client, 1).Party)
+	require.Len(t, emitter.messages, 2)
+	assert.Equal(t, "off", emitter.messages[1].Data)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "party", Data: "on"})
+	assert.Equal(t, 1, *heatArea(t, client, 1).Party)
+}
+
+func TestHandlerRouter_Handle_Presence(t *testing.T) {
+	client := mock.NewMockClient()
+	router, _ := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 2, Type: "presence", Data: "on"})
+	assert.Equal(t, 1, *heatArea(t, client, 2).Presence)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 2, Type: "presence", Data: "off"})
+	assert.Equal(t, 0, *heatArea(t, client, 2).Presence)
+}
+
+func TestHandlerRouter_Handle_PartyValidation(t *testing.T) {
+	for _, data := range []string{"", "yes", "-5", "1441", "1.5"} {
+		t.Run(data, func(t *testing.T) {
+			client := &countingClient{Client: mock.NewMockClient()}
+			router, emitter := newTestRouter(t, client)
+
+			router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "party", Data: data})
+			router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "presence", Data: data})
+
+			assert.Zero(t, client.sent)
+			assert.Empty(t, emitter.messages)
+		})
+	}
+}
+
+func TestHandlerRouter_Handle_ChildLock(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "child_lock", Data: "on"})
+	assert.Equal(t, 1, *heatArea(t, client, 1).IsLocked)
+	require.Len(t, emitter.messages, 1)
+	assert.Equal(t, "on", emitter.messages[0].Data)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "child_lock", Data: "off"})
+	assert.Equal(t, 0, *heatArea(t, client, 1).IsLocked)
+	assert.Equal(t, "1234", *heatArea(t, client, 1).LockCode)
+}
+
+func TestHandlerRouter_Handle_ChildLockValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		room int
+		data string
+	}{
+		{name: "invalid value", room: 1, data: "locked"},
+		{name: "not available", room: 2, data: "on"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingClient{Client: mock.NewMockClient()}
+			router, emitter := newTestRouter(t, client)
+
+			router.Handle(context.Background(), "device1", &api.Message{Room: tt.room, Type: "child_lock", Data: tt.data})
+
+			assert.Zero(t, client.sent)
+			assert.Empty(t, emitter.messages)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+const (
+	// unusedSlot is written to the switching times of a program which are
+	// not in use.
+	unusedSlot = "00:00"
+	// endOfDay is the end of a slot running until midnight.
+	endOfDay = "24:00"
+)
+
+// setPrograms replaces the switching times of the programs in the message.
+// A program can't have more slots than the controller reports switching
+// times for it, and its slots must be ordered and must not overlap. The
+// message is updated with all programs of the device.
+func setPrograms(t target, change *transport.Device) error {
+	var cmd api.Programs
+	dec := json.NewDecoder(bytes.NewReader([]byte(t.message.Data)))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&cmd)
+	if err != nil {
+		return fmt.Errorf("invalid programs: %w", err)
+	}
+	if len(cmd) == 0 {
+		return errors.New("invalid programs: no program given")
+	}
+	if t.device == nil || t.device.Program == nil {
+		return errors.New("programs are unknown as the device was not polled yet")
+	}
+	programs := t.device.Program.Slots()
+
+	var shiftPrograms []transport.ShiftProgram
+	for _, nr := range slices.Sorted(maps.Keys(cmd)) {
+		reported, ok := programs[nr]
+		if !ok {
+			return fmt.Errorf("unknown program: %d", nr)
+		}
+		// unused slots, as published by the controller, are filled up again
+		slots := slices.DeleteFunc(cmd[nr], func(s api.Slot) bool { return s.Start == unusedSlot && s.End == unusedSlot })
+		if len(slots) > len(reported) {
+			return fmt.Errorf("program %d has %d switching times, got %d", nr, len(reported), len(slots))
+		}
+		err = checkSlots(slots)
+		if err != nil {
+			return fmt.Errorf("invalid program %d: %w", nr, err)
+		}
+
+		updated := make([]transport.ShiftProgram, 0, len(reported))
+		for i, r := range reported {
+			start, end := unusedSlot, unusedSlot
+			if i < len(slots) {
+				start, end = slots[i].Start, slots[i].End
+			}
+			updated = append(updated, transport.ShiftProgram{Nr: &nr, ShiftingTime: r.ShiftingTime, Start: &start, End: &end})
+		}
+		programs[nr] = updated
+		shiftPrograms = append(shiftPrograms, updated...)
+	}
+	change.Program = &transport.Program{ShiftPrograms: &shiftPrograms}
+
+	state := make(api.Programs, len(programs))
+	for nr, slots := range programs {
+		state[nr] = []api.Slot{}
+		for _, s := range slots {
+			if !s.Unused() {
+				state[nr] = append(state[nr], api.Slot{Start: *s.Start, End: *s.End})
+			}
+		}
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	t.message.Data = string(data)
+	return nil
+}
+
+// checkSlots returns an error if a slot is invalid or overlaps with the
+// previous one. A slot may end at 24:00 to run until midnight.
+func checkSlots(slots []api.Slot) error {
+	var previous time.Time
+	for i, s := range slots {
+		start, err := time.Parse(transport.TimeLayout, s.Start)
+		if err != nil {
+			return fmt.Errorf("invalid start of slot %d: %q", i+1, s.Start)
+		}
+		end, err := parseSlotEnd(s.End)
+		if err != nil {
+			return fmt.Errorf("invalid end of slot %d: %q", i+1, s.End)
+		}
+		if !end.After(start) {
+			return fmt.Errorf("slot %d ends at %s before it starts at %s", i+1, s.End, s.Start)
+		}
+		if i > 0 && start.Before(previous) {
+			return fmt.Errorf("slot %d starts at %s before the previous slot ends", i+1, s.Start)
+		}
+		previous = end
+	}
+	return nil
+}
+
+// parseSlotEnd parses the end of a slot, which is either a time of day or
+// the end of the day.
+func parseSlotEnd(v string) (time.Time, error) {
+	if v == endOfDay {
+		midnight, err := time.Parse(transport.TimeLayout, "00:00")
+		return midnight.Add(24 * time.Hour), err
+	}
+	return time.Parse(transport.TimeLayout, v)
+}
+
+// setProgramWeek assigns the program used by the room on weekdays.
+func setProgramWeek(t target, change *transport.HeatArea) error {
+	nr, err := parseProgram(t)
+	if err != nil {
+		return err
+	}
+	change.ProgramWeek = &nr
+	return nil
+}
+
+// setProgramWeekend assigns the program used by the room on weekends.
+func setProgramWeekend(t target, change *transport.HeatArea) error {
+	nr, err := parseProgram(t)
+	if err != nil {
+		return err
+	}
+	change.ProgramWeekend = &nr
+	return nil
+}
+
+// parseProgram parses the number of a program reported by the device.
+func parseProgram(t target) (int, error) {
+	nr, err := strconv.Atoi(t.message.Data)
+	if err != nil {
+		return 0, fmt.Errorf("invalid program: %s", t.message.Data)
+	}
+	if t.device == nil || t.device.Program == nil {
+		return 0, errors.New("programs are unknown as the device was not polled yet")
+	}
+	if _, ok := t.device.Program.Slots()[nr]; !ok {
+		return 0, fmt.Errorf("unknown program: %d", nr)
+	}
+	return nr, nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRouter_Handle_Programs(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{
+		Room: api.DeviceRoom,
+		Type: "programs",
+		Data: `{"1":[{"start":"05:30","end":"08:00"},{"start":"16:00","end":"22:30"}]}`,
+	})
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	slots := res.Device.Program.Slots()
+	require.Len(t, slots[1], 3)
+	assert.Equal(t, "05:30", *slots[1][0].Start)
+	assert.Equal(t, "08:00", *slots[1][0].End)
+	assert.Equal(t, "16:00", *slots[1][1].Start)
+	assert.Equal(t, "22:30", *slots[1][1].End)
+	assert.True(t, slots[1][2].Unused())
+	// other programs are unchanged
+	assert.Equal(t, "17:00", *slots[2][1].Start)
+
+	require.Len(t, emitter.messages, 1)
+	assert.JSONEq(t, `{
+		"1":[{"start":"05:30","end":"08:00"},{"start":"16:00","end":"22:30"}],
+		"2":[{"start":"06:00","end":"08:00"},{"start":"17:00","end":"22:00"}]
+	}`, emitter.messages[0].Data)
+}
+
+func TestHandlerRouter_Handle_ProgramsDebounced(t *testing.T) {
+	client := mock.NewMockClient()
+	router, _ := newTestRouter(t, client)
+	d := NewDebouncer(router, router.store, 500*time.Millisecond, 2*time.Second)
+
+	// programs left out are unchanged, so both commands must be applied
+	d.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "programs", Data: `{"1":[{"start":"05:30","end":"08:00"}]}`})
+	d.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "programs", Data: `{"2":[{"start":"07:00","end":"09:00"}]}`})
+	d.Flush()
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	slots := res.Device.Program.Slots()
+	assert.Equal(t, "05:30", *slots[1][0].Start)
+	assert.Equal(t, "07:00", *slots[2][0].Start)
+}
+
+func TestHandlerRouter_Handle_ProgramsEndOfDayAndUnusedSlots(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][2]string
+	}{
+		{
+			name: "end of day",
+			data: `{"1":[{"start":"06:00","end":"08:00"},{"start":"17:00","end":"24:00"}]}`,
+			want: [][2]string{{"06:00", "08:00"}, {"17:00", "24:00"}},
+		},
+		{
+			name: "unused slots",
+			data: `{"1":[{"start":"00:00","end":"00:00"},{"start":"06:00","end":"08:00"},{"start":"00:00","end":"00:00"}]}`,
+			want: [][2]string{{"06:00", "08:00"}},
+		},
+		{
+			name: "unused slots beyond the switching times",
+			data: `{"1":[{"start":"06:00","end":"08:00"},{"start":"00:00","end":"00:00"},{"start":"12:00","end":"13:00"},{"start":"17:00","end":"24:00"}]}`,
+			want: [][2]string{{"06:00", "08:00"}, {"12:00", "13:00"}, {"17:00", "24:00"}},
+		},
+		{
+			name: "only unused slots",
+			data: `{"1":[{"start":"00:00","end":"00:00"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := mock.NewMockClient()
+			router, emitter := newTestRouter(t, client)
+
+			router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "programs", Data: tt.data})
+
+			res, err := client.Connect()
+			require.NoError(t, err)
+			slots := res.Device.Program.Slots()[1]
+			require.Len(t, slots, 3)
+			for i, s := range slots {
+				if i < len(tt.want) {
+					assert.Equal(t, tt.want[i][0], *s.Start)
+					assert.Equal(t, tt.want[i][1], *s.End)
+				} else {
+					assert.True(t, s.Unused())
+				}
+			}
+			require.Len(t, emitter.messages, 1)
+		})
+	}
+}
+
+func TestHandlerRouter_Handle_ProgramsValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "invalid json", data: `{"1":`},
+		{name: "no program", data: `{}`},
+		{name: "unknown program", data: `{"5":[]}`},
+		{name: "too many slots", data: `{"1":[{"start":"01:00","end":"02:00"},{"start":"03:00","end":"04:00"},{"start":"05:00","end":"06:00"},{"start":"07:00","end":"08:00"}]}`},
+		{name: "invalid time", data: `{"1":[{"start":"6:00am","end":"08:00"}]}`},
+		{name: "end of day as start", data: `{"1":[{"start":"24:00","end":"24:00"}]}`},
+		{name: "after end of day", data: `{"1":[{"start":"17:00","end":"24:30"}]}`},
+		{name: "end before start", data: `{"1":[{"start":"08:00","end":"06:00"}]}`},
+		{name: "overlapping slots", data: `{"1":[{"start":"06:00","end":"09:00"},{"start":"08:00","end":"10:00"}]}`},
+		{name: "unknown field", data: `{"1":[{"begin":"06:00","end":"09:00"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingClient{Client: mock.NewMockClient()}
+			router, emitter := newTestRouter(t, client)
+
+			router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "programs", Data: tt.data})
+
+			assert.Zero(t, client.sent)
+			assert.Empty(t, emitter.messages)
+		})
+	}
+}
+
+func TestHandlerRouter_Handle_ProgramWeekAndWeekend(t *testing.T) {
+	client := mock.NewMockClient()
+	router, _ := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "program_week", Data: "2"})
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "program_weekend", Data: "1"})
+
+	h := heatArea(t, client, 1)
+	assert.Equal(t, 2, *h.ProgramWeek)
+	assert.Equal(t, 1, *h.ProgramWeekend)
+
+	// unknown programs are rejected
+	counting := &countingClient{Client: client}
+	router, _ = newTestRouter(t, counting)
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "program_week", Data: "3"})
+	router.Handle(context.Background(), "device1", &api.Message{Room: 1, Type: "program_weekend", Data: "week"})
+	assert.Zero(t, counting.sent)
+	assert.Equal(t, 2, *heatArea(t, client, 1).ProgramWeek)
+	assert.Equal(t, 1, *heatArea(t, client, 1).ProgramWeekend)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// target is a room addressed by a message together with its last known
+// state. device and heatArea are nil if the device was not polled yet,
+// heatArea is also nil for messages addressing the whole device.
+type target struct {
+	message  *api.Message
+	device   *transport.Device
+	heatArea *transport.HeatArea
+}
+
+// deviceTypes are the message types which apply to the whole device and are
+// addressed with api.DeviceRoom.
+var deviceTypes = map[string]bool{
+	"vacation":               true,
+	"programs":               true,
+	"clock_sync":             true,
+	"cooling":                true,
+	"changeover":             true,
+	"summer_winter":          true,
+	"device_mode":            true,
+	"changeover_mode":        true,
+	"antifreeze":             true,
+	"antifreeze_temperature": true,
+	"eco_diff":               true,
+	"eco_input_mode":         true,
+	"smart_start":            true,
+	"first_open_time":        true,
+	"device_name":            true,
+}
+
+// resolveDevice returns the target of a message addressing the whole device.
+func resolveDevice(device *transport.Device, message *api.Message) ([]target, error) {
+	if message.Room != api.DeviceRoom || message.RoomName != "" {
+		return nil, fmt.Errorf("%s applies to the whole device, use room %d", message.Type, api.DeviceRoom)
+	}
+	return []target{{message: message, device: device}}, nil
+}
+
+// resolveRooms returns one target per room addressed by message, using the
+// last known state of the device. Room and RoomName of the returned
+// messages are both set. Rooms addressed by name or api.All can't be
+// resolved before the device was polled.
+func resolveRooms(device *transport.Device, message *api.Message) ([]target, error) {
+	if device == nil || device.HeatAreas == nil {
+		if message.RoomName != "" {
+			return nil, fmt.Errorf("room %q is unknown as the device was not polled yet", message.RoomName)
+		}
+		return []target{{message: message, device: device}}, nil
+	}
+
+	var res []target
+	for i, h := range *device.HeatAreas {
+		if h.Nr == nil || h.Name == nil {
+			continue
+		}
+		slug := api.Slug(*h.Name)
+
+		if message.RoomName == api.All ||
+			(message.RoomName != "" && slug == message.RoomName) ||
+			(message.RoomName == "" && *h.Nr == message.Room) {
+			res = append(res, target{
+				message: &api.Message{
+					Room:     *h.Nr,
+					RoomName: slug,
+					Type:     message.Type,
+					Data:     message.Data,
+				},
+				device:   device,
+				heatArea: &(*device.HeatAreas)[i],
+			})
+		}
+		if message.RoomName != api.All && len(res) > 0 {
+			return res, nil
+		}
+	}
+
+	if len(res) > 0 {
+		return res, nil
+	}
+	if message.RoomName != "" {
+		return nil, fmt.Errorf("unknown room: %s", message.RoomName)
+	}
+	return nil, fmt.Errorf("unknown room: %d", message.Room)
+}
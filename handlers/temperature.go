@@ -1,33 +1,48 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 
 	"github.com/chrishrb/ezr2mqtt/api"
 	"github.com/chrishrb/ezr2mqtt/transport"
 )
 
-func setTemperatureTarget(client transport.Client, id string, message *api.Message) error {
-	ttarget, err := strconv.ParseFloat(message.Data, 64)
-	if err != nil {
-		return fmt.Errorf("invalid temperature target value: %v", message.Data)
-	}
+// setTemperatureTarget validates the temperature target against the limits
+// of the controller and the user, rounds it to the temperature step and
+// updates the message with the normalized value.
+func setTemperatureTarget(t target, limits Limits, change *transport.HeatArea) error {
+	return setTemperature(t, limits, &change.TTarget)
+}
+
+// setTemperature validates a temperature of a room like
+// setTemperatureTarget and stores it in field.
+func setTemperature(t target, limits Limits, field **float64) error {
+	name := strings.ReplaceAll(t.message.Type, "_", " ")
 
-	msg := transport.Message{
-		Device: transport.Device{
-			ID: &id,
-			HeatAreas: &[]transport.HeatArea{{
-				Nr:      &message.Room,
-				TTarget: &ttarget,
-			}},
-		},
+	v, err := strconv.ParseFloat(t.message.Data, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("invalid %s value: %v", name, t.message.Data)
 	}
+	if t.heatArea == nil {
+		return errors.New("temperature limits are unknown as the device was not polled yet")
+	}
+
+	v = limits.round(v)
 
-	err = client.Send(&msg)
+	err = Bounds{Min: t.heatArea.TTargetMin, Max: t.heatArea.TTargetMax}.check(v, "controller")
+	if err != nil {
+		return fmt.Errorf("invalid %s for room %d: %w", name, t.message.Room, err)
+	}
+	err = limits.bounds(t.message.Room, t.message.RoomName).check(v, "configured")
 	if err != nil {
-		return fmt.Errorf("error sending temperature target: %w", err)
+		return fmt.Errorf("invalid %s for room %d: %w", name, t.message.Room, err)
 	}
 
+	t.message.Data = api.FormatFloat(v)
+	*field = &v
 	return nil
 }
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// setVacation applies a partial api.Vacation to the current vacation of the
+// device. The resulting vacation is validated as a whole, so that e.g.
+// enabling it fails if the stored end lies before the start, and the message
+// is updated with the complete vacation.
+func setVacation(t target, limits Limits, change *transport.Device) error {
+	var cmd api.Vacation
+	dec := json.NewDecoder(bytes.NewReader([]byte(t.message.Data)))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&cmd)
+	if err != nil {
+		return fmt.Errorf("invalid vacation: %w", err)
+	}
+	if t.device == nil || t.device.Vacation == nil {
+		return errors.New("vacation is unknown as the device was not polled yet")
+	}
+	current := t.device.Vacation
+
+	enabled := current.State != nil && *current.State == 1
+	if cmd.Enabled != nil {
+		enabled = *cmd.Enabled
+	}
+
+	start, err := vacationTime(cmd.Start, current.StartDate, current.StartTime)
+	if err != nil {
+		return fmt.Errorf("invalid vacation start: %w", err)
+	}
+	end, err := vacationTime(cmd.End, current.EndDate, current.EndTime)
+	if err != nil {
+		return fmt.Errorf("invalid vacation end: %w", err)
+	}
+	if enabled && !end.After(start) {
+		return fmt.Errorf("vacation end %s is not after its start %s", end.Format(api.VacationTimeLayout), start.Format(api.VacationTimeLayout))
+	}
+
+	temperature := t.device.THeatVacation
+	if cmd.Temperature != nil {
+		v := *cmd.Temperature
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("invalid vacation temperature: %v", v)
+		}
+		v = limits.round(v)
+		minimum, maximum := t.device.TargetRange()
+		err = Bounds{Min: minimum, Max: maximum}.check(v, "controller")
+		if err != nil {
+			return fmt.Errorf("invalid vacation temperature: %w", err)
+		}
+		temperature = &v
+		change.THeatVacation = &v
+	}
+
+	vacation := transport.Vacation{}
+	if cmd.Enabled != nil {
+		state := 0
+		if enabled {
+			state = 1
+		}
+		vacation.State = &state
+	}
+	if cmd.Start != nil {
+		vacation.StartDate, vacation.StartTime = formatDateTime(start)
+	}
+	if cmd.End != nil {
+		vacation.EndDate, vacation.EndTime = formatDateTime(end)
+	}
+	if vacation != (transport.Vacation{}) {
+		change.Vacation = &vacation
+	}
+
+	startStr, endStr := start.Format(api.VacationTimeLayout), end.Format(api.VacationTimeLayout)
+	data, err := json.Marshal(api.Vacation{
+		Enabled:     &enabled,
+		Start:       &startStr,
+		End:         &endStr,
+		Temperature: temperature,
+	})
+	if err != nil {
+		return err
+	}
+	t.message.Data = string(data)
+	return nil
+}
+
+// vacationTime parses v if set and falls back to the date and time reported
+// by the controller otherwise.
+func vacationTime(v *string, date, clock *string) (time.Time, error) {
+	if v != nil {
+		return time.ParseInLocation(api.VacationTimeLayout, *v, time.UTC)
+	}
+	if date == nil || clock == nil {
+		return time.Time{}, errors.New("not reported by the device")
+	}
+	return transport.ParseDateTime(*date, *clock)
+}
+
+func formatDateTime(t time.Time) (date, clock *string) {
+	d, c := t.Format(transport.DateLayout), t.Format(transport.TimeLayout)
+	return &d, &c
+}
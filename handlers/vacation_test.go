@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRouter_Handle_Vacation(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{
+		Room: api.DeviceRoom,
+		Type: "vacation",
+		Data: `{"enabled":true,"start":"2025-12-24T18:00","end":"2026-01-06T12:00","temperature":16.1}`,
+	})
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	vacation := res.Device.Vacation
+	assert.Equal(t, 1, *vacation.State)
+	assert.Equal(t, "24.12.2025", *vacation.StartDate)
+	assert.Equal(t, "18:00", *vacation.StartTime)
+	assert.Equal(t, "06.01.2026", *vacation.EndDate)
+	assert.Equal(t, "12:00", *vacation.EndTime)
+	assert.Equal(t, 16.0, *res.Device.THeatVacation)
+
+	require.Len(t, emitter.messages, 1)
+	assert.Equal(t, api.DeviceRoom, emitter.messages[0].Room)
+	assert.JSONEq(t, `{"enabled":true,"start":"2025-12-24T18:00","end":"2026-01-06T12:00","temperature":16}`, emitter.messages[0].Data)
+}
+
+func TestHandlerRouter_Handle_VacationPartial(t *testing.T) {
+	client := mock.NewMockClient()
+	router, emitter := newTestRouter(t, client)
+
+	router.Handle(context.Background(), "device1", &api.Message{Room: api.DeviceRoom, Type: "vacation", Data: `{"temperature":12}`})
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, *res.Device.THeatVacation)
+	assert.Equal(t, 0, *res.Device.Vacation.State)
+	require.Len(t, emitter.messages, 1)
+	assert.JSONEq(t, `{"enabled":false,"start":"2025-01-01T00:00","end":"2025-01-01T00:00","temperature":12}`, emitter.messages[0].Data)
+}
+
+func TestHandlerRouter_Handle_VacationValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		room int
+		data string
+	}{
+		{name: "invalid json", data: `{"enabled":`},
+		{name: "unknown field", data: `{"enable":true}`},
+		{name: "invalid date format", data: `{"start":"24.12.2025 18:00"}`},
+		{name: "invalid date", data: `{"start":"2025-02-30T18:00"}`},
+		{name: "end before start", data: `{"enabled":true,"start":"2026-01-06T12:00","end":"2025-12-24T18:00"}`},
+		// the mock device has start and end at the same time
+		{name: "enabled without end", data: `{"enabled":true}`},
+		{name: "temperature above maximum", data: `{"temperature":35}`},
+		{name: "room instead of device", room: 1, data: `{"enabled":false}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &countingClient{Client: mock.NewMockClient()}
+			router, emitter := newTestRouter(t, client)
+
+			router.Handle(context.Background(), "device1", &api.Message{Room: tt.room, Type: "vacation", Data: tt.data})
+
+			assert.Zero(t, client.sent)
+			assert.Empty(t, emitter.messages)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package polling
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// isCooling reports whether the system is switched to cooling.
+func isCooling(device *transport.Device) bool {
+	return device.Cooling != nil && *device.Cooling != 0
+}
+
+// publishChangeover publishes whether the system heats or cools and the
+// settings deciding it. The modes are published as raw values of the
+// controller.
+func (r *Poller) publishChangeover(ctx context.Context, device *transport.Device) {
+	rm := room{nr: api.DeviceRoom}
+	for _, f := range []struct {
+		typ   string
+		value *int
+	}{
+		{typ: "cooling", value: device.Cooling},
+		{typ: "changeover", value: device.Changeover},
+		{typ: "summer_winter", value: device.SummerWinter},
+	} {
+		if f.value != nil {
+			r.sendMsg(ctx, rm, f.typ, api.OnOff(*f.value))
+		}
+	}
+
+	if device.Mode != nil {
+		r.sendMsg(ctx, rm, "device_mode", strconv.Itoa(*device.Mode))
+	}
+	if device.ChangeoverFunc != nil && device.ChangeoverFunc.Mode != nil {
+		r.sendMsg(ctx, rm, "changeover_mode", strconv.Itoa(*device.ChangeoverFunc.Mode))
+	}
+}
+
+// discoverChangeover publishes switches for the flags deciding whether the
+// system heats or cools.
+func (r *Poller) discoverChangeover(ctx context.Context, published map[entity]struct{}, device *transport.Device, deviceName string) {
+	rm := room{nr: api.DeviceRoom}
+	haDevice := &api.HADevice{
+		Identifiers: []string{*device.ID},
+		Name:        deviceName,
+	}
+
+	for _, f := range []struct {
+		typ   string
+		name  string
+		icon  string
+		value *int
+	}{
+		{typ: "cooling", name: "Cooling", icon: "mdi:snowflake", value: device.Cooling},
+		{typ: "changeover", name: "Changeover", icon: "mdi:sun-snowflake-variant", value: device.Changeover},
+		{typ: "summer_winter", name: "Summer/Winter Switchover", icon: "mdi:weather-sunny", value: device.SummerWinter},
+	} {
+		if f.value == nil {
+			continue
+		}
+		d := api.HASensorDiscovery{
+			Name:         f.name,
+			UniqueID:     fmt.Sprintf("%s-%s", r.name, f.typ),
+			StateTopic:   r.stateTopic(rm, f.typ),
+			CommandTopic: r.commandTopic(rm, f.typ),
+			PayloadOn:    "on",
+			PayloadOff:   "off",
+			StateOn:      "on",
+			StateOff:     "off",
+			Icon:         f.icon,
+			Device:       haDevice,
+		}
+		if f.typ != "cooling" {
+			d.EntityCategory = "config"
+		}
+		r.emitHADiscovery(ctx, published, api.HAComponentSwitch, d)
+	}
+}
@@ -0,0 +1,59 @@
+package polling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller_PollOnce_Changeover(t *testing.T) {
+	client := mock.NewMockClient()
+	err := client.Send(&transport.Message{Device: transport.Device{Cooling: ptr(1)}})
+	require.NoError(t, err)
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	data := make(map[string]string)
+	for _, msg := range emitter.messagesOf("cooling", "changeover", "summer_winter", "device_mode", "changeover_mode", "hvac_action") {
+		data[emitter.StateTopic("device1", msg)] = msg.Data
+	}
+	assert.Equal(t, map[string]string{
+		"ezr/device1/0/state/cooling":         "on",
+		"ezr/device1/0/state/changeover":      "on",
+		"ezr/device1/0/state/summer_winter":   "off",
+		"ezr/device1/0/state/device_mode":     "1",
+		"ezr/device1/0/state/changeover_mode": "0",
+		"ezr/device1/1/state/hvac_action":     "cooling",
+		"ezr/device1/2/state/hvac_action":     "cooling",
+	}, data)
+
+	require.Len(t, emitter.discoveriesOf("cooling", "changeover", "summer_winter"), 3)
+
+	emitter.Lock()
+	defer emitter.Unlock()
+	var climate *api.HASensorDiscovery
+	for _, d := range emitter.discoveries {
+		if d.UniqueID == "device1-2-climate" {
+			climate = &d
+		}
+	}
+	require.NotNil(t, climate)
+	assert.Equal(t, []string{"heat", "cool"}, climate.Modes)
+	assert.Equal(t, "ezr/device1/0/state/cooling", climate.ModeStateTopic)
+	assert.Equal(t, "ezr/device1/0/set/cooling", climate.ModeCommandTopic)
+}
+
+func TestChanged_Cooling(t *testing.T) {
+	previous := &transport.Device{Cooling: ptr(0)}
+	assert.False(t, changed(previous, &transport.Device{Cooling: ptr(0)}))
+	assert.True(t, changed(previous, &transport.Device{Cooling: ptr(1)}))
+}
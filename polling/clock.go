@@ -0,0 +1,122 @@
+package polling
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// WithClockSync sets the clock of the controller to the clock of the host
+// whenever it drifts by more than maxDrift.
+func WithClockSync(maxDrift time.Duration) Opt {
+	return func(p *Poller) {
+		p.maxClockDrift = maxDrift
+	}
+}
+
+// clockDrift returns how far the clock of the controller is ahead of now,
+// ok is false if the controller didn't report a valid clock.
+func clockDrift(device *transport.Device, now time.Time) (drift time.Duration, ok bool) {
+	if device.DateTime == nil {
+		return 0, false
+	}
+	t, err := transport.ParseClock(*device.DateTime, now.Location())
+	if err != nil {
+		return 0, false
+	}
+	return t.Sub(now), true
+}
+
+// sameZone reports whether the host and the controller are in the same time
+// zone. The controller reports the offset of its standard time in hours, so
+// it is compared with the standard time of the host. A controller that
+// doesn't report its time zone is assumed to be in the zone of the host.
+func sameZone(device *transport.Device, now time.Time) bool {
+	if device.TimeZone == nil {
+		return true
+	}
+	_, jan := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location()).Zone()
+	_, jul := time.Date(now.Year(), time.July, 1, 0, 0, 0, 0, now.Location()).Zone()
+	return min(jan, jul) == *device.TimeZone*3600
+}
+
+// checkClock publishes the drift of the clock of the controller and corrects
+// it if clock sync is enabled and the drift exceeds the maximum. The clock is
+// not corrected if the host is in another time zone than the controller, as
+// the drift would be the difference of the zones.
+func (r *Poller) checkClock(ctx context.Context, device *transport.Device, publish bool) {
+	now := r.now()
+	drift, ok := clockDrift(device, now)
+	if !ok {
+		return
+	}
+	if publish {
+		r.sendMsg(ctx, room{nr: api.DeviceRoom}, "clock_drift", strconv.FormatInt(int64(drift.Round(time.Second)/time.Second), 10))
+	}
+
+	if r.maxClockDrift <= 0 || drift.Abs() <= r.maxClockDrift {
+		return
+	}
+	if !sameZone(device, now) {
+		if !r.zoneMismatch {
+			r.zoneMismatch = true
+			slog.Warn("not syncing clock, the host is in another time zone than the controller",
+				"device_name", r.name, "time_zone", *device.TimeZone, "host_time_zone", now.Location())
+		}
+		return
+	}
+	if !r.lastClockSync.IsZero() && now.Sub(r.lastClockSync) < r.clockSyncEvery {
+		return
+	}
+	r.lastClockSync = now
+
+	dateTime, dayOfWeek := transport.Clock(now)
+	err := r.client.Send(&transport.Message{Device: transport.Device{
+		ID:        device.ID,
+		DateTime:  &dateTime,
+		DayOfWeek: &dayOfWeek,
+	}})
+	if err != nil {
+		slog.Error("error syncing clock", "device_name", r.name, "error", err)
+		return
+	}
+	slog.Info("clock synced", "device_name", r.name, "drift", drift.Round(time.Second))
+}
+
+// discoverClock publishes the clock drift and a button to sync the clock.
+func (r *Poller) discoverClock(ctx context.Context, published map[entity]struct{}, device *transport.Device, deviceName string) {
+	if device.DateTime == nil {
+		return
+	}
+	rm := room{nr: api.DeviceRoom}
+	haDevice := &api.HADevice{
+		Identifiers: []string{*device.ID},
+		Name:        deviceName,
+	}
+
+	r.emitHADiscovery(ctx, published, api.HAComponentSensor, api.HASensorDiscovery{
+		Name:              "Clock Drift",
+		UniqueID:          fmt.Sprintf("%s-clock_drift", r.name),
+		StateTopic:        r.stateTopic(rm, "clock_drift"),
+		UnitOfMeasurement: "s",
+		DeviceClass:       "duration",
+		StateClass:        "measurement",
+		EntityCategory:    "diagnostic",
+		Device:            haDevice,
+	})
+
+	r.emitHADiscovery(ctx, published, api.HAComponentButton, api.HASensorDiscovery{
+		Name:           "Sync Clock",
+		UniqueID:       fmt.Sprintf("%s-clock_sync", r.name),
+		CommandTopic:   r.commandTopic(rm, "clock_sync"),
+		PayloadPress:   "PRESS",
+		Icon:           "mdi:clock-edit-outline",
+		EntityCategory: "config",
+		Device:         haDevice,
+	})
+}
@@ -0,0 +1,141 @@
+package polling
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller_PollOnce_ClockDrift(t *testing.T) {
+	client := mock.NewMockClient()
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	messages := emitter.messagesOf("clock_drift")
+	require.Len(t, messages, 1)
+	drift, err := strconv.Atoi(messages[0].Data)
+	require.NoError(t, err)
+	// the mock clock stands still in 2025
+	assert.Less(t, drift, 0)
+
+	// sync is disabled by default
+	res, err := client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-01T12:00:00", *res.Device.DateTime)
+
+	discoveries := emitter.discoveriesOf("clock_drift")
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "device1-clock_drift", discoveries[0].UniqueID)
+}
+
+func TestPoller_PollOnce_ClockSync(t *testing.T) {
+	client := mock.NewMockClient()
+	emitter := &fakeEmitter{}
+	// the mock controller is in UTC+1
+	cet := time.FixedZone("CET", 3600)
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore(), WithClockSync(time.Minute))
+	poller.now = func() time.Time { return time.Now().In(cet) }
+	poller.poll(context.Background(), nil)
+
+	res, err := client.Connect()
+	require.NoError(t, err)
+	synced, err := transport.ParseClock(*res.Device.DateTime, cet)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), synced, 5*time.Second)
+
+	// in sync, nothing is written
+	inSync := "2025-01-01T12:00:00"
+	err = client.Send(&transport.Message{Device: transport.Device{DateTime: &inSync}})
+	require.NoError(t, err)
+	poller.maxClockDrift = 10 * 365 * 24 * time.Hour
+	poller.poll(context.Background(), nil)
+	res, err = client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, inSync, *res.Device.DateTime)
+}
+
+func TestPoller_PollOnce_ClockSyncRateLimited(t *testing.T) {
+	client := mock.NewMockClient()
+	cet := time.FixedZone("CET", 3600)
+	now := time.Now().In(cet)
+
+	poller := NewPoller("device1", client, &fakeEmitter{}, time.Hour, store.NewInMemoryStore(), WithClockSync(time.Minute))
+	poller.now = func() time.Time { return now }
+	poller.poll(context.Background(), nil)
+	synced, _ := transport.Clock(now)
+	res, err := client.Connect()
+	require.NoError(t, err)
+	require.Equal(t, synced, *res.Device.DateTime)
+
+	// drifted again right away, not written before the interval passed
+	drifted := "2025-01-01T12:00:00"
+	err = client.Send(&transport.Message{Device: transport.Device{DateTime: &drifted}})
+	require.NoError(t, err)
+	now = now.Add(10 * time.Minute)
+	poller.poll(context.Background(), nil)
+	res, err = client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, drifted, *res.Device.DateTime)
+
+	now = now.Add(time.Hour)
+	poller.poll(context.Background(), nil)
+	synced, _ = transport.Clock(now)
+	res, err = client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, synced, *res.Device.DateTime)
+}
+
+func TestPoller_PollOnce_ClockSyncOtherZone(t *testing.T) {
+	client := mock.NewMockClient()
+	emitter := &fakeEmitter{}
+	now := time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)
+
+	// the host runs in UTC, the controller in UTC+1 shows the same instant
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore(), WithClockSync(time.Minute))
+	poller.now = func() time.Time { return now }
+	poller.poll(context.Background(), nil)
+
+	// the drift is the difference of the zones and not corrected
+	messages := emitter.messagesOf("clock_drift")
+	require.Len(t, messages, 1)
+	assert.Equal(t, "3600", messages[0].Data)
+	res, err := client.Connect()
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-01T12:00:00", *res.Device.DateTime)
+}
+
+func TestSameZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	summer := time.Date(2025, 7, 1, 12, 0, 0, 0, berlin)
+
+	assert.True(t, sameZone(&transport.Device{TimeZone: ptr(1)}, summer))
+	assert.False(t, sameZone(&transport.Device{TimeZone: ptr(0)}, summer))
+	assert.False(t, sameZone(&transport.Device{TimeZone: ptr(1)}, summer.In(time.UTC)))
+	assert.True(t, sameZone(&transport.Device{TimeZone: ptr(0)}, summer.In(time.UTC)))
+	// unknown zone of the controller
+	assert.True(t, sameZone(&transport.Device{}, summer.In(time.UTC)))
+}
+
+func TestClockDrift(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	drift, ok := clockDrift(&transport.Device{DateTime: ptr("2025-01-01T12:00:00")}, now)
+	require.True(t, ok)
+	assert.Equal(t, -30*time.Second, drift)
+
+	_, ok = clockDrift(&transport.Device{DateTime: ptr("01.01.2025 12:00")}, now)
+	assert.False(t, ok)
+	_, ok = clockDrift(&transport.Device{}, now)
+	assert.False(t, ok)
+}
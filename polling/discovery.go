@@ -0,0 +1,492 @@
+package polling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// entity identifies an entity announced in the Home Assistant discovery
+type entity struct {
+	component api.HAComponent
+	uniqueID  string
+}
+
+// discover stores the ID of the device and publishes the Home Assistant
+// discovery of its entities. Entities of a previous discovery which no
+// longer exist, e.g. because a room was renamed, are removed.
+func (r *Poller) discover(ctx context.Context, device *transport.Device) error {
+	if device.ID == nil {
+		return errors.New("device reported no ID")
+	}
+	r.store.SetID(r.name, *device.ID)
+
+	if r.entities == nil {
+		r.removeLegacyEntities(ctx, device)
+	}
+	published := make(map[entity]struct{})
+
+	deviceName := valueOr(device.Name, r.name)
+	haDevice := &api.HADevice{
+		Identifiers: []string{*device.ID},
+		Name:        deviceName,
+	}
+	programs := programNumbers(device)
+	valves := valvesOf(device)
+
+	if device.HeatAreas != nil {
+		for _, h := range *device.HeatAreas {
+			if h.Nr == nil || h.Name == nil {
+				continue
+			}
+			roomName := removeUmlauts(*h.Name)
+			rm := room{nr: *h.Nr, slug: api.Slug(*h.Name)}
+
+			r.emitHADiscovery(ctx, published, api.HAComponentNumber, api.HASensorDiscovery{
+				Name:              fmt.Sprintf("%s Temperature Target", roomName),
+				UniqueID:          r.roomUniqueID(rm, "temperature_target"),
+				StateTopic:        r.stateTopic(rm, "temperature_target"),
+				UnitOfMeasurement: "°C",
+				DeviceClass:       "temperature",
+				StateClass:        "measurement",
+				CommandTopic:      r.commandTopic(rm, "temperature_target"),
+				Minimum:           valueOr(h.TTargetMin, 5),
+				Maximum:           valueOr(h.TTargetMax, 30),
+				Step:              r.temperatureStep,
+				Mode:              "slider",
+				Device:            haDevice,
+			})
+
+			r.emitHADiscovery(ctx, published, api.HAComponentSensor, api.HASensorDiscovery{
+				Name:              fmt.Sprintf("%s Temperature Actual", roomName),
+				UniqueID:          r.roomUniqueID(rm, "temperature_actual"),
+				StateTopic:        r.stateTopic(rm, "temperature_actual"),
+				UnitOfMeasurement: "°C",
+				DeviceClass:       "temperature",
+				StateClass:        "measurement",
+				Device:            haDevice,
+			})
+
+			if h.HasExtSensor() {
+				r.emitHADiscovery(ctx, published, api.HAComponentSensor, api.HASensorDiscovery{
+					Name:              fmt.Sprintf("%s Temperature External", roomName),
+					UniqueID:          r.roomUniqueID(rm, "temperature_external"),
+					StateTopic:        r.stateTopic(rm, "temperature_external"),
+					UnitOfMeasurement: "°C",
+					DeviceClass:       "temperature",
+					StateClass:        "measurement",
+					Device:            haDevice,
+				})
+			}
+
+			if h.Offset != nil {
+				r.emitHADiscovery(ctx, published, api.HAComponentNumber, api.HASensorDiscovery{
+					Name:              fmt.Sprintf("%s Temperature Offset", roomName),
+					UniqueID:          r.roomUniqueID(rm, "temperature_offset"),
+					StateTopic:        r.stateTopic(rm, "temperature_offset"),
+					CommandTopic:      r.commandTopic(rm, "temperature_offset"),
+					UnitOfMeasurement: "°C",
+					Minimum:           -transport.MaxOffset,
+					Maximum:           transport.MaxOffset,
+					Step:              transport.OffsetStep,
+					Mode:              "box",
+					Icon:              "mdi:thermometer-lines",
+					EntityCategory:    "config",
+					Device:            haDevice,
+				})
+			}
+
+			r.emitHADiscovery(ctx, published, api.HAComponentText, api.HASensorDiscovery{
+				Name:           fmt.Sprintf("%s Name", roomName),
+				UniqueID:       r.roomUniqueID(rm, "room_name"),
+				StateTopic:     r.stateTopic(rm, "room_name"),
+				CommandTopic:   r.commandTopic(rm, "room_name"),
+				Minimum:        1,
+				Maximum:        transport.MaxNameLength,
+				Icon:           "mdi:rename",
+				EntityCategory: "config",
+				Device:         haDevice,
+			})
+
+			r.emitHADiscovery(ctx, published, api.HAComponentSelect, api.HASensorDiscovery{
+				Name:         fmt.Sprintf("%s Heatarea Mode", roomName),
+				UniqueID:     r.roomUniqueID(rm, "heatarea_mode"),
+				StateTopic:   r.stateTopic(rm, "heatarea_mode"),
+				CommandTopic: r.commandTopic(rm, "heatarea_mode"),
+				Options: []string{
+					"auto",
+					"day",
+					"night",
+				},
+				Device: haDevice,
+			})
+
+			climate := api.HASensorDiscovery{
+				Name:                    roomName,
+				UniqueID:                r.roomUniqueID(rm, "climate"),
+				CurrentTemperatureTopic: r.stateTopic(rm, "temperature_actual"),
+				TemperatureStateTopic:   r.stateTopic(rm, "temperature_target"),
+				TemperatureCommandTopic: r.commandTopic(rm, "temperature_target"),
+				TemperatureUnit:         "C",
+				MinTemp:                 valueOr(h.TTargetMin, 5),
+				MaxTemp:                 valueOr(h.TTargetMax, 30),
+				TempStep:                r.temperatureStep,
+				Modes:                   []string{"heat"},
+				ModeStateTopic:          r.stateTopic(rm, "heatarea_mode"),
+				ModeStateTemplate:       "heat",
+				PresetModes:             []string{"auto", "day", "night"},
+				PresetModeStateTopic:    r.stateTopic(rm, "heatarea_mode"),
+				PresetModeCommandTopic:  r.commandTopic(rm, "heatarea_mode"),
+				Device:                  haDevice,
+			}
+			if device.Cooling != nil {
+				// heating and cooling are switched for the whole system
+				climate.Modes = []string{"heat", "cool"}
+				climate.ModeStateTopic = r.stateTopic(room{nr: api.DeviceRoom}, "cooling")
+				climate.ModeStateTemplate = "{{ 'cool' if value == 'on' else 'heat' }}"
+				climate.ModeCommandTopic = r.commandTopic(room{nr: api.DeviceRoom}, "cooling")
+				climate.ModeCommandTemplate = "{{ 'on' if value == 'cool' else 'off' }}"
+			}
+			if _, ok := valves[rm.nr]; ok {
+				climate.ActionTopic = r.stateTopic(rm, "hvac_action")
+			}
+			r.emitHADiscovery(ctx, published, api.HAComponentClimate, climate)
+
+			if _, ok := valves[rm.nr]; ok {
+				r.emitHADiscovery(ctx, published, api.HAComponentBinarySensor, api.HASensorDiscovery{
+					Name:        fmt.Sprintf("%s Heating Active", roomName),
+					UniqueID:    r.roomUniqueID(rm, "heating_active"),
+					StateTopic:  r.stateTopic(rm, "heating_active"),
+					PayloadOn:   "on",
+					PayloadOff:  "off",
+					DeviceClass: "heat",
+					Device:      haDevice,
+				})
+
+				r.emitHADiscovery(ctx, published, api.HAComponentSensor, api.HASensorDiscovery{
+					Name:              fmt.Sprintf("%s Valve Opening", roomName),
+					UniqueID:          r.roomUniqueID(rm, "valve_opening"),
+					StateTopic:        r.stateTopic(rm, "valve_opening"),
+					UnitOfMeasurement: "%",
+					StateClass:        "measurement",
+					Icon:              "mdi:valve",
+					Device:            haDevice,
+				})
+			}
+
+			for _, sp := range setpointsOf(&h) {
+				if sp.value == nil {
+					continue
+				}
+				r.emitHADiscovery(ctx, published, api.HAComponentNumber, api.HASensorDiscovery{
+					Name:              fmt.Sprintf("%s %s", roomName, sp.name),
+					UniqueID:          r.roomUniqueID(rm, sp.typ),
+					StateTopic:        r.stateTopic(rm, sp.typ),
+					UnitOfMeasurement: "°C",
+					DeviceClass:       "temperature",
+					CommandTopic:      r.commandTopic(rm, sp.typ),
+					Minimum:           valueOr(h.TTargetMin, 5),
+					Maximum:           valueOr(h.TTargetMax, 30),
+					Step:              r.temperatureStep,
+					Mode:              "box",
+					Device:            haDevice,
+				})
+			}
+
+			if h.Party != nil {
+				r.emitHADiscovery(ctx, published, api.HAComponentSwitch, api.HASensorDiscovery{
+					Name:         fmt.Sprintf("%s Party", roomName),
+					UniqueID:     r.roomUniqueID(rm, "party"),
+					StateTopic:   r.stateTopic(rm, "party"),
+					CommandTopic: r.commandTopic(rm, "party"),
+					PayloadOn:    "on",
+					PayloadOff:   "off",
+					StateOn:      "on",
+					StateOff:     "off",
+					Icon:         "mdi:party-popper",
+					Device:       haDevice,
+				})
+
+				// setting the remaining time starts a party of that duration
+				r.emitHADiscovery(ctx, published, api.HAComponentNumber, api.HASensorDiscovery{
+					Name:              fmt.Sprintf("%s Party Remaining Time", roomName),
+					UniqueID:          r.roomUniqueID(rm, "party_remaining_time"),
+					StateTopic:        r.stateTopic(rm, "party_remaining_time"),
+					CommandTopic:      r.commandTopic(rm, "party"),
+					UnitOfMeasurement: "min",
+					DeviceClass:       "duration",
+					Minimum:           0,
+					Maximum:           transport.MaxPartyDuration,
+					Step:              1,
+					Mode:              "box",
+					Device:            haDevice,
+				})
+			}
+
+			for _, p := range []struct {
+				typ  string
+				name string
+				nr   *int
+			}{
+				{typ: "program_week", name: "Program Week", nr: h.ProgramWeek},
+				{typ: "program_weekend", name: "Program Weekend", nr: h.ProgramWeekend},
+			} {
+				if p.nr == nil || len(programs) == 0 {
+					continue
+				}
+				r.emitHADiscovery(ctx, published, api.HAComponentSelect, api.HASensorDiscovery{
+					Name:         fmt.Sprintf("%s %s", roomName, p.name),
+					UniqueID:     r.roomUniqueID(rm, p.typ),
+					StateTopic:   r.stateTopic(rm, p.typ),
+					CommandTopic: r.commandTopic(rm, p.typ),
+					Options:      programs,
+					Icon:         "mdi:calendar-clock",
+					Device:       haDevice,
+				})
+			}
+
+			if h.Presence != nil {
+				r.emitHADiscovery(ctx, published, api.HAComponentSwitch, api.HASensorDiscovery{
+					Name:         fmt.Sprintf("%s Presence", roomName),
+					UniqueID:     r.roomUniqueID(rm, "presence"),
+					StateTopic:   r.stateTopic(rm, "presence"),
+					CommandTopic: r.commandTopic(rm, "presence"),
+					PayloadOn:    "on",
+					PayloadOff:   "off",
+					StateOn:      "on",
+					StateOff:     "off",
+					Icon:         "mdi:home-account",
+					Device:       haDevice,
+				})
+			}
+
+			if h.Lockable() {
+				r.emitHADiscovery(ctx, published, api.HAComponentSwitch, api.HASensorDiscovery{
+					Name:           fmt.Sprintf("%s Child Lock", roomName),
+					UniqueID:       r.roomUniqueID(rm, "child_lock"),
+					StateTopic:     r.stateTopic(rm, "child_lock"),
+					CommandTopic:   r.commandTopic(rm, "child_lock"),
+					PayloadOn:      "on",
+					PayloadOff:     "off",
+					StateOn:        "on",
+					StateOff:       "off",
+					Icon:           "mdi:lock",
+					EntityCategory: "config",
+					Device:         haDevice,
+				})
+			}
+		}
+	}
+
+	if device.Vacation != nil {
+		r.discoverVacation(ctx, published, device, deviceName)
+	}
+
+	if device.Name != nil {
+		r.emitHADiscovery(ctx, published, api.HAComponentText, api.HASensorDiscovery{
+			Name:           "Name",
+			UniqueID:       fmt.Sprintf("%s-device_name", r.name),
+			StateTopic:     r.stateTopic(room{nr: api.DeviceRoom}, "device_name"),
+			CommandTopic:   r.commandTopic(room{nr: api.DeviceRoom}, "device_name"),
+			Minimum:        1,
+			Maximum:        transport.MaxNameLength,
+			Icon:           "mdi:rename",
+			EntityCategory: "config",
+			Device:         haDevice,
+		})
+	}
+
+	r.discoverIODevices(ctx, published, device, deviceName)
+	r.discoverMeta(ctx, published, device, deviceName)
+	r.discoverClock(ctx, published, device, deviceName)
+	r.discoverChangeover(ctx, published, device, deviceName)
+	r.discoverInstallerSettings(ctx, published, device, deviceName)
+
+	if device.Program != nil {
+		r.emitHADiscovery(ctx, published, api.HAComponentSensor, api.HASensorDiscovery{
+			Name:                "Programs",
+			UniqueID:            fmt.Sprintf("%s-programs", r.name),
+			StateTopic:          r.stateTopic(room{nr: api.DeviceRoom}, "programs"),
+			ValueTemplate:       "{{ value_json | length }}",
+			JSONAttributesTopic: r.stateTopic(room{nr: api.DeviceRoom}, "programs"),
+			Icon:                "mdi:calendar-clock",
+			Device:              haDevice,
+		})
+	}
+
+	for e := range r.entities {
+		if _, ok := published[e]; ok {
+			continue
+		}
+		err := r.emitter.RemoveHADiscovery(ctx, e.component, e.uniqueID)
+		if err != nil {
+			slog.Error("error removing discovery", "unique_id", e.uniqueID, "error", err)
+			// retry with the next discovery
+			published[e] = struct{}{}
+		}
+	}
+	r.entities = published
+	r.layout = layoutOf(device)
+	// tracked again by the poll following the discovery
+	r.parties = make(map[int]party)
+
+	slog.Info("device discovered", "device_name", r.name, "id", *device.ID)
+	return nil
+}
+
+// discoverVacation publishes the entities controlling the vacation mode,
+// which all share the JSON state and command topics of the vacation.
+func (r *Poller) discoverVacation(ctx context.Context, published map[entity]struct{}, device *transport.Device, deviceName string) {
+	rm := room{nr: api.DeviceRoom}
+	haDevice := &api.HADevice{
+		Identifiers: []string{*device.ID},
+		Name:        deviceName,
+	}
+	minimum, maximum := device.TargetRange()
+
+	r.emitHADiscovery(ctx, published, api.HAComponentSwitch, api.HASensorDiscovery{
+		Name:          "Vacation",
+		UniqueID:      fmt.Sprintf("%s-vacation", r.name),
+		StateTopic:    r.stateTopic(rm, "vacation"),
+		ValueTemplate: "{{ 'ON' if value_json.enabled else 'OFF' }}",
+		StateOn:       "ON",
+		StateOff:      "OFF",
+		CommandTopic:  r.commandTopic(rm, "vacation"),
+		PayloadOn:     `{"enabled":true}`,
+		PayloadOff:    `{"enabled":false}`,
+		Icon:          "mdi:airplane",
+		Device:        haDevice,
+	})
+
+	r.emitHADiscovery(ctx, published, api.HAComponentNumber, api.HASensorDiscovery{
+		Name:              "Vacation Temperature",
+		UniqueID:          fmt.Sprintf("%s-vacation_temperature", r.name),
+		StateTopic:        r.stateTopic(rm, "vacation"),
+		ValueTemplate:     "{{ value_json.temperature }}",
+		CommandTopic:      r.commandTopic(rm, "vacation"),
+		CommandTemplate:   `{"temperature":{{ value }}}`,
+		UnitOfMeasurement: "°C",
+		DeviceClass:       "temperature",
+		Minimum:           valueOr(minimum, 5),
+		Maximum:           valueOr(maximum, 30),
+		Step:              r.temperatureStep,
+		Mode:              "box",
+		Device:            haDevice,
+	})
+
+	// each entity sends a partial vacation, they are merged by the handler
+	// with the current vacation and must not be debounced
+	for _, field := range []string{"start", "end"} {
+		r.emitHADiscovery(ctx, published, api.HAComponentText, api.HASensorDiscovery{
+			Name:            fmt.Sprintf("Vacation %s", strings.ToUpper(field[:1])+field[1:]),
+			UniqueID:        fmt.Sprintf("%s-vacation_%s", r.name, field),
+			StateTopic:      r.stateTopic(rm, "vacation"),
+			ValueTemplate:   fmt.Sprintf("{{ value_json.%s }}", field),
+			CommandTopic:    r.commandTopic(rm, "vacation"),
+			CommandTemplate: fmt.Sprintf(`{"%s":"{{ value }}"}`, field),
+			Pattern:         `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}$`,
+			Icon:            "mdi:calendar",
+			Device:          haDevice,
+		})
+	}
+}
+
+// programNumbers returns the numbers of the programs reported by the
+// device, used as options of the program selects.
+func programNumbers(device *transport.Device) []string {
+	var res []string
+	if device.Program != nil {
+		for _, nr := range slices.Sorted(maps.Keys(device.Program.Slots())) {
+			res = append(res, strconv.Itoa(nr))
+		}
+	}
+	return res
+}
+
+// layoutOf returns a fingerprint of everything the discovery depends on.
+func layoutOf(device *transport.Device) string {
+	valves := valvesOf(device)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s", valueOr(device.ID, ""), valueOr(device.Name, ""))
+	if device.HeatAreas != nil {
+		for _, h := range *device.HeatAreas {
+			fmt.Fprintf(&b, "|%d:%s:%v:%v:%t:%t:%t:%t:%t:%t:%t", valueOr(h.Nr, -1), valueOr(h.Name, ""), valueOr(h.TTargetMin, 0), valueOr(h.TTargetMax, 0),
+				h.Party != nil, h.Presence != nil, h.ProgramWeek != nil, h.ProgramWeekend != nil, h.Lockable(), h.Offset != nil, h.HasExtSensor())
+			for _, sp := range setpointsOf(&h) {
+				fmt.Fprintf(&b, ":%t", sp.value != nil)
+			}
+			_, ok := valves[valueOr(h.Nr, -1)]
+			fmt.Fprintf(&b, ":%t", ok)
+		}
+	}
+	fmt.Fprintf(&b, "|vacation:%t|clock:%t|programs:%v", device.Vacation != nil, device.DateTime != nil, programNumbers(device))
+	fmt.Fprintf(&b, "|changeover:%t:%t:%t", device.Cooling != nil, device.Changeover != nil, device.SummerWinter != nil)
+	b.WriteString(installerLayoutOf(device))
+	b.WriteString(ioLayoutOf(device))
+	return b.String()
+}
+
+// removeLegacyEntities removes the entities of earlier versions, whose
+// unique IDs were keyed on the room name. It runs before the first discovery
+// after a start, so that Home Assistant doesn't keep them next to the
+// current entities and the current entities get their entity IDs.
+func (r *Poller) removeLegacyEntities(ctx context.Context, device *transport.Device) {
+	if device.HeatAreas == nil {
+		return
+	}
+	for _, h := range *device.HeatAreas {
+		if h.Nr == nil || h.Name == nil {
+			continue
+		}
+		for _, roomID := range slices.Compact([]string{strings.ToLower(removeUmlauts(*h.Name)), api.Slug(*h.Name)}) {
+			if roomID == strconv.Itoa(*h.Nr) {
+				// the same as the current unique ID
+				continue
+			}
+			for _, e := range []entity{
+				{component: api.HAComponentNumber, uniqueID: fmt.Sprintf("%s-%s-temperature_target", r.name, roomID)},
+				{component: api.HAComponentSensor, uniqueID: fmt.Sprintf("%s-%s-temperature_actual", r.name, roomID)},
+				{component: api.HAComponentSelect, uniqueID: fmt.Sprintf("%s-%s-heatarea_mode", r.name, roomID)},
+			} {
+				r.removeHADiscovery(ctx, e.component, e.uniqueID)
+			}
+		}
+	}
+}
+
+// roomUniqueID returns the unique ID of an entity of a room. It is keyed on
+// the room number, so that a renamed room keeps its entities.
+func (r *Poller) roomUniqueID(rm room, typ string) string {
+	return fmt.Sprintf("%s-%d-%s", r.name, rm.nr, typ)
+}
+
+func (r *Poller) emitHADiscovery(ctx context.Context, published map[entity]struct{}, component api.HAComponent, message api.HASensorDiscovery) {
+	published[entity{component: component, uniqueID: message.UniqueID}] = struct{}{}
+	err := r.emitter.EmitHADiscovery(ctx, component, message)
+	if err != nil {
+		slog.Error("error emitting discovery", "unique_id", message.UniqueID, "error", err)
+	}
+}
+
+func (r *Poller) removeHADiscovery(ctx context.Context, component api.HAComponent, uniqueID string) {
+	err := r.emitter.RemoveHADiscovery(ctx, component, uniqueID)
+	if err != nil {
+		slog.Error("error removing discovery", "unique_id", uniqueID, "error", err)
+	}
+}
+
+func valueOr[T any](v *T, fallback T) T {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
@@ -0,0 +1,58 @@
+package polling
+
+import (
+	"math"
+
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// valves aggregates the valve outputs of a room.
+type valves struct {
+	active  bool
+	opening int
+}
+
+// action returns the hvac action of a room for Home Assistant.
+func (v valves) action(cooling bool) string {
+	switch {
+	case !v.active:
+		return "idle"
+	case cooling:
+		return "cooling"
+	default:
+		return "heating"
+	}
+}
+
+// valvesOf aggregates the valve outputs in use by room. A room is active if
+// any of its valves is open, opening is the average opening in percent.
+func valvesOf(device *transport.Device) map[int]valves {
+	res := make(map[int]valves)
+	if device.HeatCtrls == nil {
+		return res
+	}
+
+	type sum struct{ percent, count int }
+	sums := make(map[int]sum)
+	for _, c := range *device.HeatCtrls {
+		if c.HeatAreaNr == nil || c.InUse == nil || *c.InUse == 0 {
+			continue
+		}
+		percent := 0
+		if c.ActorPercent != nil {
+			percent = *c.ActorPercent
+		} else if c.Actor != nil && *c.Actor != 0 {
+			percent = 100
+		}
+		s := sums[*c.HeatAreaNr]
+		sums[*c.HeatAreaNr] = sum{percent: s.percent + percent, count: s.count + 1}
+	}
+
+	for nr, s := range sums {
+		res[nr] = valves{
+			active:  s.percent > 0,
+			opening: int(math.Round(float64(s.percent) / float64(s.count))),
+		}
+	}
+	return res
+}
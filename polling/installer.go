@@ -0,0 +1,168 @@
+package polling
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// WithInstallerSettings announces the installer settings as writable
+// entities instead of read-only sensors.
+func WithInstallerSettings() Opt {
+	return func(p *Poller) {
+		p.installerSettings = true
+	}
+}
+
+// installerSetting is a setting of the whole device usually changed by the
+// installer. data is nil if the controller doesn't report it.
+type installerSetting struct {
+	typ  string
+	name string
+	icon string
+	data *string
+	// flags are on or off, all other settings are numbers
+	flag bool
+	unit string
+	min  float64
+	max  float64
+	step float64
+}
+
+func installerSettingsOf(device *transport.Device, temperatureStep float64) []installerSetting {
+	flag := func(v *int) *string {
+		if v == nil {
+			return nil
+		}
+		s := api.OnOff(*v)
+		return &s
+	}
+	float := func(v *float64) *string {
+		if v == nil {
+			return nil
+		}
+		s := api.FormatFloat(*v)
+		return &s
+	}
+	integer := func(v *int) *string {
+		if v == nil {
+			return nil
+		}
+		s := strconv.Itoa(*v)
+		return &s
+	}
+
+	return []installerSetting{
+		{typ: "antifreeze", name: "Antifreeze", icon: "mdi:snowflake-alert", data: flag(device.Antifreeze), flag: true},
+		{typ: "antifreeze_temperature", name: "Antifreeze Temperature", icon: "mdi:snowflake-thermometer", data: float(device.AntifreezeTemp),
+			unit: "°C", min: transport.MinAntifreezeTemp, max: transport.MaxAntifreezeTemp, step: temperatureStep},
+		{typ: "eco_diff", name: "Eco Reduction", icon: "mdi:leaf", data: float(device.EcoDiff),
+			unit: "°C", min: 0, max: transport.MaxEcoDiff, step: temperatureStep},
+		{typ: "smart_start", name: "Smart Start", icon: "mdi:clock-fast", data: flag(device.SmartStart), flag: true},
+		{typ: "first_open_time", name: "First Open Time", icon: "mdi:valve-open", data: integer(device.FirstOpenTime),
+			unit: "min", min: 0, max: transport.MaxFirstOpenTime, step: 1},
+		// the raw mode of the controller, published but not announced
+		{typ: "eco_input_mode", data: integer(device.EcoInputMode)},
+	}
+}
+
+// publishInstallerSettings publishes the installer settings and the state
+// of the eco input.
+func (r *Poller) publishInstallerSettings(ctx context.Context, device *transport.Device) {
+	rm := room{nr: api.DeviceRoom}
+	for _, s := range installerSettingsOf(device, r.temperatureStep) {
+		if s.data != nil {
+			r.sendMsg(ctx, rm, s.typ, *s.data)
+		}
+	}
+	if device.EcoInputState != nil {
+		r.sendMsg(ctx, rm, "eco_input_state", api.OnOff(*device.EcoInputState))
+	}
+}
+
+// discoverInstallerSettings announces the installer settings as config
+// entities, which are read-only unless installer settings are enabled.
+func (r *Poller) discoverInstallerSettings(ctx context.Context, published map[entity]struct{}, device *transport.Device, deviceName string) {
+	rm := room{nr: api.DeviceRoom}
+	haDevice := &api.HADevice{
+		Identifiers: []string{*device.ID},
+		Name:        deviceName,
+	}
+
+	for _, s := range installerSettingsOf(device, r.temperatureStep) {
+		if s.data == nil || s.name == "" {
+			continue
+		}
+		d := api.HASensorDiscovery{
+			Name:       s.name,
+			StateTopic: r.stateTopic(rm, s.typ),
+			Icon:       s.icon,
+			Device:     haDevice,
+		}
+
+		// the writable component and the read-only one
+		var component, other api.HAComponent
+		if s.flag {
+			component, other = api.HAComponentSwitch, api.HAComponentBinarySensor
+			d.PayloadOn, d.PayloadOff = "on", "off"
+		} else {
+			component, other = api.HAComponentNumber, api.HAComponentSensor
+			d.UnitOfMeasurement = s.unit
+		}
+		if r.installerSettings {
+			d.CommandTopic = r.commandTopic(rm, s.typ)
+			d.EntityCategory = "config"
+			if s.flag {
+				d.StateOn, d.StateOff = "on", "off"
+			} else {
+				d.Minimum, d.Maximum, d.Step = s.min, s.max, s.step
+				d.Mode = "box"
+			}
+		} else {
+			component, other = other, component
+			d.EntityCategory = "diagnostic"
+		}
+
+		if r.entities == nil {
+			// installer settings were toggled while we were not running
+			r.removeHADiscovery(ctx, other, r.installerUniqueID(s.typ, other))
+		}
+		d.UniqueID = r.installerUniqueID(s.typ, component)
+		r.emitHADiscovery(ctx, published, component, d)
+	}
+
+	if device.EcoInputState != nil {
+		r.emitHADiscovery(ctx, published, api.HAComponentBinarySensor, api.HASensorDiscovery{
+			Name:           "Eco Input",
+			UniqueID:       r.installerUniqueID("eco_input_state", api.HAComponentBinarySensor),
+			StateTopic:     r.stateTopic(rm, "eco_input_state"),
+			PayloadOn:      "on",
+			PayloadOff:     "off",
+			Icon:           "mdi:leaf",
+			EntityCategory: "diagnostic",
+			Device:         haDevice,
+		})
+	}
+}
+
+// installerUniqueID returns the unique ID of an installer setting. It
+// includes the component, as a setting is either writable or read-only.
+func (r *Poller) installerUniqueID(typ string, component api.HAComponent) string {
+	return fmt.Sprintf("%s-%s-%s", r.name, typ, component)
+}
+
+// installerLayoutOf returns a fingerprint of the installer settings for
+// layoutOf.
+func installerLayoutOf(device *transport.Device) string {
+	var b strings.Builder
+	b.WriteString("|installer")
+	for _, s := range installerSettingsOf(device, 0) {
+		fmt.Fprintf(&b, ":%t", s.data != nil)
+	}
+	fmt.Fprintf(&b, ":%t", device.EcoInputState != nil)
+	return b.String()
+}
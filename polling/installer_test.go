@@ -0,0 +1,85 @@
+package polling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var installerTypes = []string{"antifreeze", "antifreeze_temperature", "eco_diff", "eco_input_mode", "smart_start", "first_open_time", "eco_input_state"}
+
+func TestPoller_PollOnce_InstallerSettings(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", mock.NewMockClient(), emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	data := make(map[string]string)
+	for _, msg := range emitter.messagesOf(installerTypes...) {
+		assert.Equal(t, api.DeviceRoom, msg.Room)
+		data[msg.Type] = msg.Data
+	}
+	assert.Equal(t, map[string]string{
+		"antifreeze":             "on",
+		"antifreeze_temperature": "5.00",
+		"eco_diff":               "2.00",
+		"eco_input_mode":         "0",
+		"smart_start":            "off",
+		"first_open_time":        "10",
+		"eco_input_state":        "off",
+	}, data)
+
+	// read-only unless installer settings are enabled
+	emitter.Lock()
+	defer emitter.Unlock()
+	components := make(map[string]api.HAComponent)
+	for i, d := range emitter.discoveries {
+		components[d.UniqueID] = emitter.components[i]
+		if d.UniqueID == "device1-antifreeze-binary_sensor" {
+			assert.Empty(t, d.CommandTopic)
+			assert.Equal(t, "diagnostic", d.EntityCategory)
+		}
+	}
+	assert.Equal(t, api.HAComponentBinarySensor, components["device1-antifreeze-binary_sensor"])
+	assert.Equal(t, api.HAComponentSensor, components["device1-antifreeze_temperature-sensor"])
+	assert.NotContains(t, components, "device1-eco_input_mode-sensor")
+}
+
+func TestPoller_PollOnce_InstallerSettingsWritable(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	client := mock.NewMockClient()
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore(), WithInstallerSettings())
+	poller.poll(context.Background(), nil)
+
+	discoveries := emitter.discoveriesOf(installerTypes...)
+	require.Len(t, discoveries, 6)
+	for _, d := range discoveries[:5] {
+		assert.Equal(t, "config", d.EntityCategory, d.UniqueID)
+		assert.NotEmpty(t, d.CommandTopic, d.UniqueID)
+	}
+	assert.Equal(t, "ezr/device1/0/set/antifreeze_temperature", discoveries[1].CommandTopic)
+	assert.Equal(t, 15.0, discoveries[1].Maximum)
+	assert.Equal(t, 0.5, discoveries[1].Step)
+	assert.Equal(t, "device1-eco_input_state-binary_sensor", discoveries[5].UniqueID)
+
+	// the read-only entities are replaced by the writable ones, once
+	assert.Subset(t, emitter.removed, []string{
+		"device1-antifreeze-binary_sensor",
+		"device1-antifreeze_temperature-sensor",
+		"device1-eco_diff-sensor",
+		"device1-smart_start-binary_sensor",
+		"device1-first_open_time-sensor",
+	})
+	removed := len(emitter.removed)
+	res, err := client.Connect()
+	require.NoError(t, err)
+	require.NoError(t, poller.discover(context.Background(), &res.Device))
+	assert.Len(t, emitter.removed, removed)
+}
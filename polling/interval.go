@@ -0,0 +1,171 @@
+package polling
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// WithActivePolling polls every activeEvery instead of the regular interval
+// for activeFor after a command or a change detected on the device.
+func WithActivePolling(activeEvery, activeFor time.Duration) Opt {
+	return func(p *Poller) {
+		p.activeEvery = activeEvery
+		p.activeFor = activeFor
+	}
+}
+
+// WithBackoff doubles the interval after each failed poll up to maxBackoff
+// while the device is unreachable.
+func WithBackoff(maxBackoff time.Duration) Opt {
+	return func(p *Poller) {
+		p.maxBackoff = maxBackoff
+	}
+}
+
+// WithJitter delays each poll by a random duration of up to jitter, so that
+// several devices aren't polled at the same moment.
+func WithJitter(jitter time.Duration) Opt {
+	return func(p *Poller) {
+		p.jitter = jitter
+	}
+}
+
+// WithRetryDelay sets the delay of the first retry if the device couldn't
+// be discovered. It is doubled for each further retry up to the backoff.
+func WithRetryDelay(retryDelay time.Duration) Opt {
+	return func(p *Poller) {
+		p.retryDelay = retryDelay
+	}
+}
+
+// activate switches to the active interval.
+func (r *Poller) activate() {
+	if r.activeFor <= 0 {
+		return
+	}
+	r.intervalMu.Lock()
+	defer r.intervalMu.Unlock()
+	r.activeUntil = time.Now().Add(r.activeFor)
+}
+
+// nextInterval returns the time to wait for the next periodic poll.
+func (r *Poller) nextInterval() time.Duration {
+	r.intervalMu.Lock()
+	defer r.intervalMu.Unlock()
+
+	d := r.runEvery
+	limit := max(r.runEvery, r.maxBackoff)
+	switch {
+	case !r.discovered && r.failures == 0:
+		// discover right away
+		d = 0
+	case !r.discovered:
+		d = min(r.retryDelay, limit)
+		for i := 1; i < r.failures && d < limit; i++ {
+			d *= 2
+		}
+		d = min(d, limit)
+	case r.failures > 0:
+		for i := 0; i < r.failures && d < limit; i++ {
+			d *= 2
+		}
+		d = min(d, limit)
+	case r.activeEvery > 0 && time.Now().Before(r.activeUntil):
+		d = min(d, r.activeEvery)
+	}
+
+	if r.jitter > 0 {
+		//#nosec G404 - jitter does not require secure random number generator
+		d += rand.N(r.jitter)
+	}
+	return d
+}
+
+// recordPoll updates the state used by nextInterval with the result of a
+// poll.
+func (r *Poller) recordPoll(ok bool) {
+	r.intervalMu.Lock()
+	defer r.intervalMu.Unlock()
+
+	if ok {
+		if r.failures > 0 && r.discovered {
+			slog.Info("device is reachable again", "device_name", r.name, "failures", r.failures)
+			// the device may have been reset or replaced meanwhile
+			r.discovered = false
+		}
+		r.failures = 0
+	} else {
+		r.failures++
+	}
+}
+
+func (r *Poller) isDiscovered() bool {
+	r.intervalMu.Lock()
+	defer r.intervalMu.Unlock()
+	return r.discovered
+}
+
+func (r *Poller) setDiscovered(discovered bool) {
+	r.intervalMu.Lock()
+	defer r.intervalMu.Unlock()
+	r.discovered = discovered
+}
+
+// changed reports whether the settings of the device or of a heat area
+// differ between the two states. Measured values are ignored as they change
+// all the time.
+func changed(previous, current *transport.Device) bool {
+	if previous == nil {
+		return false
+	}
+	if !equal(previous.Cooling, current.Cooling) || !equal(previous.Changeover, current.Changeover) ||
+		!equal(previous.SummerWinter, current.SummerWinter) {
+		return true
+	}
+	previousSettings := installerSettingsOf(previous, 0)
+	for i, s := range installerSettingsOf(current, 0) {
+		if !equal(previousSettings[i].data, s.data) {
+			return true
+		}
+	}
+	if previous.HeatAreas == nil || current.HeatAreas == nil {
+		return false
+	}
+
+	settings := make(map[int]transport.HeatArea, len(*previous.HeatAreas))
+	for _, h := range *previous.HeatAreas {
+		if h.Nr != nil {
+			settings[*h.Nr] = h
+		}
+	}
+
+	for _, h := range *current.HeatAreas {
+		if h.Nr == nil {
+			continue
+		}
+		p, ok := settings[*h.Nr]
+		if !ok || !equal(p.TTarget, h.TTarget) || !equal(p.Mode, h.Mode) ||
+			!equal(p.Party, h.Party) || !equal(p.Presence, h.Presence) ||
+			!equal(p.IsLocked, h.IsLocked) || !equal(p.Offset, h.Offset) ||
+			!equal(p.ProgramWeek, h.ProgramWeek) || !equal(p.ProgramWeekend, h.ProgramWeekend) {
+			return true
+		}
+		previous := setpointsOf(&p)
+		for i, sp := range setpointsOf(&h) {
+			if !equal(previous[i].value, sp.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func equal[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,103 @@
+package polling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller_NextInterval(t *testing.T) {
+	poller := NewPoller("device1", mock.NewMockClient(), &fakeEmitter{}, time.Minute, store.NewInMemoryStore(),
+		WithActivePolling(10*time.Second, time.Minute),
+		WithBackoff(5*time.Minute),
+	)
+
+	poller.setDiscovered(true)
+	assert.Equal(t, time.Minute, poller.nextInterval())
+
+	poller.activate()
+	assert.Equal(t, 10*time.Second, poller.nextInterval())
+
+	// unreachable devices back off, even if active
+	for _, expected := range []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		poller.recordPoll(false)
+		assert.Equal(t, expected, poller.nextInterval())
+	}
+
+	// the device is rediscovered right away once it is reachable again
+	poller.recordPoll(true)
+	assert.Equal(t, time.Duration(0), poller.nextInterval())
+
+	poller.setDiscovered(true)
+	assert.Equal(t, 10*time.Second, poller.nextInterval())
+}
+
+func TestPoller_NextInterval_WithoutBackoff(t *testing.T) {
+	poller := NewPoller("device1", mock.NewMockClient(), &fakeEmitter{}, time.Minute, store.NewInMemoryStore())
+	poller.setDiscovered(true)
+
+	poller.recordPoll(false)
+	assert.Equal(t, time.Minute, poller.nextInterval())
+
+	// active polling is disabled by default
+	poller.activate()
+	poller.recordPoll(true)
+	poller.setDiscovered(true)
+	assert.Equal(t, time.Minute, poller.nextInterval())
+}
+
+func TestPoller_NextInterval_Jitter(t *testing.T) {
+	poller := NewPoller("device1", mock.NewMockClient(), &fakeEmitter{}, time.Minute, store.NewInMemoryStore(), WithJitter(5*time.Second))
+	poller.setDiscovered(true)
+
+	for range 100 {
+		d := poller.nextInterval()
+		assert.GreaterOrEqual(t, d, time.Minute)
+		assert.Less(t, d, time.Minute+5*time.Second)
+	}
+}
+
+func TestPoller_Poll_ActivatesOnChange(t *testing.T) {
+	client := mock.NewMockClient()
+	poller := NewPoller("device1", client, &fakeEmitter{}, time.Minute, store.NewInMemoryStore(),
+		WithActivePolling(10*time.Second, time.Minute),
+	)
+
+	poller.poll(context.Background(), nil)
+	poller.poll(context.Background(), nil)
+	assert.Equal(t, time.Minute, poller.nextInterval())
+
+	// changed at the device
+	err := client.Send(&transport.Message{Device: transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Nr: ptr(1), Mode: ptr(2)}},
+	}})
+	require.NoError(t, err)
+
+	poller.poll(context.Background(), nil)
+	assert.Equal(t, 10*time.Second, poller.nextInterval())
+}
+
+func TestPoller_NextInterval_Discovery(t *testing.T) {
+	poller := NewPoller("device1", mock.NewMockClient(), &fakeEmitter{}, time.Minute, store.NewInMemoryStore(),
+		WithRetryDelay(5*time.Second),
+		WithBackoff(30*time.Second),
+	)
+
+	// the first poll runs right away
+	assert.Equal(t, time.Duration(0), poller.nextInterval())
+
+	for _, expected := range []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute} {
+		poller.recordPoll(false)
+		assert.Equal(t, expected, poller.nextInterval())
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,183 @@
+package polling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// ioRoom returns the room an IO device is linked to and the name of the
+// room without umlauts. IO devices which aren't linked to a known room
+// belong to the whole device and have no room name.
+func ioRoom(device *transport.Device, io *transport.IODevice) (room, string) {
+	if io.HeatAreaNr != nil && device.HeatAreas != nil {
+		for _, h := range *device.HeatAreas {
+			if h.Nr != nil && h.Name != nil && *h.Nr == *io.HeatAreaNr {
+				return room{nr: *h.Nr, slug: api.Slug(*h.Name)}, removeUmlauts(*h.Name)
+			}
+		}
+	}
+	return room{nr: api.DeviceRoom}, ""
+}
+
+// publishIODevices publishes the state of the IO devices linked to the
+// given rooms, or of all IO devices if rooms is nil.
+func (r *Poller) publishIODevices(ctx context.Context, device *transport.Device, rooms map[int]struct{}) {
+	if device.IODevices == nil {
+		return
+	}
+	for _, io := range *device.IODevices {
+		if io.Nr == nil {
+			continue
+		}
+		rm, _ := ioRoom(device, &io)
+		if _, ok := rooms[rm.nr]; rooms != nil && !ok {
+			continue
+		}
+
+		data, err := json.Marshal(ioDeviceOf(&io))
+		if err != nil {
+			slog.Error("error encoding io device", "nr", *io.Nr, "error", err)
+			continue
+		}
+		r.sendMsg(ctx, rm, ioDeviceType(*io.Nr), string(data))
+	}
+}
+
+func ioDeviceOf(io *transport.IODevice) api.IODevice {
+	return api.IODevice{
+		Nr:             *io.Nr,
+		Type:           io.Type,
+		ID:             io.ID,
+		Room:           io.HeatAreaNr,
+		BatteryLow:     isSet(io.Battery),
+		SignalStrength: io.SignalStrength,
+		ComError:       isSet(io.ComError),
+		State:          io.State,
+		VersionHW:      io.VersHW,
+		VersionSW:      io.VersSW,
+	}
+}
+
+// ioDeviceKinds names the known types of IO devices.
+var ioDeviceKinds = map[int]string{
+	1: "Room Unit",
+}
+
+// ioDeviceName returns the name of an IO device in Home Assistant, e.g.
+// "Living Room Room Unit 1". Devices of unknown types are named by their
+// type number, e.g. "IO Device 3 (Type 5)".
+func ioDeviceName(roomName string, io *transport.IODevice) string {
+	name := fmt.Sprintf("IO Device %d", *io.Nr)
+	if io.Type != nil {
+		if kind, ok := ioDeviceKinds[*io.Type]; ok {
+			name = fmt.Sprintf("%s %d", kind, *io.Nr)
+		} else {
+			name = fmt.Sprintf("%s (Type %d)", name, *io.Type)
+		}
+	}
+	return strings.TrimSpace(roomName + " " + name)
+}
+
+func ioDeviceType(nr int) string {
+	return fmt.Sprintf("iodevice_%d", nr)
+}
+
+// isSet converts a flag reported by the controller, nil stays nil.
+func isSet(v *int) *bool {
+	if v == nil {
+		return nil
+	}
+	b := *v != 0
+	return &b
+}
+
+// discoverIODevices publishes diagnostic entities of the IO devices, which
+// all share the JSON state topic of their IO device.
+func (r *Poller) discoverIODevices(ctx context.Context, published map[entity]struct{}, device *transport.Device, deviceName string) {
+	if device.IODevices == nil {
+		return
+	}
+	haDevice := &api.HADevice{
+		Identifiers: []string{*device.ID},
+		Name:        deviceName,
+	}
+
+	for _, io := range *device.IODevices {
+		if io.Nr == nil {
+			continue
+		}
+		rm, roomName := ioRoom(device, &io)
+		name := ioDeviceName(roomName, &io)
+		uniqueID := fmt.Sprintf("%s-%s", r.name, ioDeviceType(*io.Nr))
+		stateTopic := r.stateTopic(rm, ioDeviceType(*io.Nr))
+
+		if io.Battery != nil {
+			r.emitHADiscovery(ctx, published, api.HAComponentBinarySensor, api.HASensorDiscovery{
+				Name:           fmt.Sprintf("%s Battery", name),
+				UniqueID:       fmt.Sprintf("%s-battery", uniqueID),
+				StateTopic:     stateTopic,
+				ValueTemplate:  "{{ 'ON' if value_json.battery_low else 'OFF' }}",
+				DeviceClass:    "battery",
+				EntityCategory: "diagnostic",
+				Device:         haDevice,
+			})
+		}
+
+		if io.SignalStrength != nil {
+			r.emitHADiscovery(ctx, published, api.HAComponentSensor, api.HASensorDiscovery{
+				Name:              fmt.Sprintf("%s Signal Strength", name),
+				UniqueID:          fmt.Sprintf("%s-signal_strength", uniqueID),
+				StateTopic:        stateTopic,
+				ValueTemplate:     "{{ value_json.signal_strength }}",
+				UnitOfMeasurement: "%",
+				StateClass:        "measurement",
+				Icon:              "mdi:wifi",
+				EntityCategory:    "diagnostic",
+				Device:            haDevice,
+			})
+		}
+
+		if io.ComError != nil {
+			r.emitHADiscovery(ctx, published, api.HAComponentBinarySensor, api.HASensorDiscovery{
+				Name:           fmt.Sprintf("%s Communication Error", name),
+				UniqueID:       fmt.Sprintf("%s-com_error", uniqueID),
+				StateTopic:     stateTopic,
+				ValueTemplate:  "{{ 'ON' if value_json.com_error else 'OFF' }}",
+				DeviceClass:    "problem",
+				EntityCategory: "diagnostic",
+				Device:         haDevice,
+			})
+		}
+
+		if io.VersSW != nil {
+			r.emitHADiscovery(ctx, published, api.HAComponentSensor, api.HASensorDiscovery{
+				Name:                fmt.Sprintf("%s Firmware", name),
+				UniqueID:            fmt.Sprintf("%s-firmware", uniqueID),
+				StateTopic:          stateTopic,
+				ValueTemplate:       "{{ value_json.version_sw }}",
+				JSONAttributesTopic: stateTopic,
+				Icon:                "mdi:chip",
+				EntityCategory:      "diagnostic",
+				Device:              haDevice,
+			})
+		}
+	}
+}
+
+// ioLayoutOf returns a fingerprint of the IO devices for layoutOf.
+func ioLayoutOf(device *transport.Device) string {
+	var b strings.Builder
+	if device.IODevices != nil {
+		for _, io := range *device.IODevices {
+			fmt.Fprintf(&b, "|io%d:%d:%t:%t:%t:%t", valueOr(io.Nr, -1), valueOr(io.HeatAreaNr, -1),
+				io.Battery != nil, io.SignalStrength != nil, io.ComError != nil, io.VersSW != nil)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package polling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller_PollOnce_IODevices(t *testing.T) {
+	client := mock.NewMockClient()
+	// not linked to a room
+	err := client.Send(&transport.Message{Device: transport.Device{
+		IODevices: &[]transport.IODevice{{Nr: ptr(3), Type: ptr(5), HeatAreaNr: ptr(0), Battery: ptr(0)}},
+	}})
+	require.NoError(t, err)
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	messages := emitter.messagesOf("iodevice_1", "iodevice_2", "iodevice_3")
+	require.Len(t, messages, 3)
+	assert.Equal(t, 1, messages[0].Room)
+	assert.Equal(t, "living_room", messages[0].RoomName)
+	assert.JSONEq(t, `{"nr":1,"type":1,"id":10001,"room":1,"battery_low":false,"signal_strength":80,"com_error":false,"state":0,"version_hw":"01.00","version_sw":"01.04"}`, messages[0].Data)
+	assert.Equal(t, 2, messages[1].Room)
+	assert.Contains(t, messages[1].Data, `"battery_low":true`)
+	assert.Equal(t, api.DeviceRoom, messages[2].Room)
+
+	discoveries := emitter.discoveriesOf("iodevice_1", "iodevice_2", "iodevice_3")
+	require.Len(t, discoveries, 9)
+	assert.Equal(t, "Living Room Room Unit 1 Battery", discoveries[0].Name)
+	assert.Equal(t, "device1-iodevice_1-battery", discoveries[0].UniqueID)
+	assert.Equal(t, "ezr/device1/1/state/iodevice_1", discoveries[0].StateTopic)
+	assert.Equal(t, "battery", discoveries[0].DeviceClass)
+	assert.Equal(t, "diagnostic", discoveries[0].EntityCategory)
+	assert.Equal(t, "IO Device 3 (Type 5) Battery", discoveries[8].Name)
+}
+
+func TestIODeviceName(t *testing.T) {
+	tests := []struct {
+		name     string
+		roomName string
+		io       transport.IODevice
+		expected string
+	}{
+		{name: "room unit", roomName: "Living Room", io: transport.IODevice{Nr: ptr(1), Type: ptr(1)}, expected: "Living Room Room Unit 1"},
+		{name: "not linked to a room", io: transport.IODevice{Nr: ptr(2), Type: ptr(1)}, expected: "Room Unit 2"},
+		{name: "unknown type", roomName: "Bedroom", io: transport.IODevice{Nr: ptr(3), Type: ptr(5)}, expected: "Bedroom IO Device 3 (Type 5)"},
+		{name: "no type", io: transport.IODevice{Nr: ptr(4)}, expected: "IO Device 4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ioDeviceName(tt.roomName, &tt.io))
+		})
+	}
+}
+
+func TestPoller_Refresh_IODevicesOfRoom(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", mock.NewMockClient(), emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+	polled := len(emitter.messagesOf("iodevice_1", "iodevice_2"))
+
+	poller.poll(context.Background(), map[int]struct{}{2: {}})
+	messages := emitter.messagesOf("iodevice_1", "iodevice_2")
+	require.Len(t, messages, polled+1)
+	assert.Equal(t, "iodevice_2", messages[polled].Type)
+}
@@ -0,0 +1,108 @@
+package polling
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// metaOf returns the diagnostics of the device with its secrets redacted.
+func metaOf(device *transport.Device) api.Meta {
+	res := api.Meta{
+		ID:         device.ID,
+		Type:       device.Type,
+		Name:       device.Name,
+		ErrorCount: device.ErrorCount,
+		DateTime:   device.DateTime,
+		TimeZone:   device.TimeZone,
+		NTPSync:    isSet(device.NTPSync),
+		VersionSTM: device.VersSWSTM,
+		VersionETH: device.VersSWETH,
+		VersionHW:  device.VersHW,
+	}
+	if n := device.Network; n != nil {
+		res.Network = &api.MetaNetwork{
+			MAC:     n.MAC,
+			DHCP:    isSet(n.DHCP),
+			IPv4:    n.IPv4Actual,
+			Netmask: n.NetmaskActual,
+			Gateway: n.Gateway,
+			DNS:     n.DNS,
+			IPv6:    n.IPv6Actual,
+		}
+	}
+	if c := device.Cloud; c != nil {
+		res.Cloud = &api.MetaCloud{
+			UserID:   c.UserID,
+			Password: redact(c.Password),
+			Server:   c.M2MServerAddress,
+			Active:   isSet(c.M2MActive),
+			State:    c.M2MState,
+		}
+	}
+	if device.Code != nil {
+		res.ExpertCode = redact(device.Code.Expert)
+	}
+	return res
+}
+
+// redact hides a secret, unset or empty secrets are kept to show that none
+// is configured.
+func redact(secret *string) *string {
+	if secret == nil || *secret == "" {
+		return secret
+	}
+	redacted := api.Redacted
+	return &redacted
+}
+
+// discoverMeta publishes diagnostic entities of the controller, which all
+// share the JSON state topic of the diagnostics.
+func (r *Poller) discoverMeta(ctx context.Context, published map[entity]struct{}, device *transport.Device, deviceName string) {
+	stateTopic := r.stateTopic(room{nr: api.DeviceRoom}, "meta")
+	haDevice := &api.HADevice{
+		Identifiers: []string{*device.ID},
+		Name:        deviceName,
+	}
+
+	sensors := []struct {
+		typ      string
+		name     string
+		template string
+		icon     string
+	}{
+		{typ: "error_count", name: "Error Count", template: "{{ value_json.error_count }}", icon: "mdi:alert-circle-outline"},
+		{typ: "firmware", name: "Firmware", template: "{{ value_json.version_sw_eth }}", icon: "mdi:chip"},
+		{typ: "ip_address", name: "IP Address", template: "{{ value_json.network.ipv4 if value_json.network is defined }}", icon: "mdi:ip-network"},
+		{typ: "clock", name: "Clock", template: "{{ value_json.date_time }}", icon: "mdi:clock-outline"},
+	}
+	for _, s := range sensors {
+		d := api.HASensorDiscovery{
+			Name:           s.name,
+			UniqueID:       fmt.Sprintf("%s-%s", r.name, s.typ),
+			StateTopic:     stateTopic,
+			ValueTemplate:  s.template,
+			Icon:           s.icon,
+			EntityCategory: "diagnostic",
+			Device:         haDevice,
+		}
+		if s.typ == "error_count" {
+			d.StateClass = "measurement"
+			// all diagnostics as attributes of one entity
+			d.JSONAttributesTopic = stateTopic
+		}
+		r.emitHADiscovery(ctx, published, api.HAComponentSensor, d)
+	}
+
+	r.emitHADiscovery(ctx, published, api.HAComponentBinarySensor, api.HASensorDiscovery{
+		Name:           "Time Synchronization",
+		UniqueID:       fmt.Sprintf("%s-ntp_sync", r.name),
+		StateTopic:     stateTopic,
+		ValueTemplate:  "{{ 'ON' if value_json.ntp_sync else 'OFF' }}",
+		Icon:           "mdi:clock-check-outline",
+		EntityCategory: "diagnostic",
+		Device:         haDevice,
+	})
+}
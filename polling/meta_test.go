@@ -0,0 +1,58 @@
+package polling
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller_PollOnce_Meta(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", mock.NewMockClient(), emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	messages := emitter.messagesOf("meta")
+	require.Len(t, messages, 1)
+	assert.Equal(t, api.DeviceRoom, messages[0].Room)
+	assert.JSONEq(t, `{
+		"id":"MOCK-12345","type":"EZR","name":"Mock Device",
+		"error_count":0,"date_time":"2025-01-01T12:00:00","time_zone":1,"ntp_sync":true,
+		"version_sw_stm":"02.02","version_sw_eth":"02.10","version_hw":"00.00",
+		"network":{"mac":"00:11:22:33:44:55","dhcp":true,"ipv4":"192.168.1.100","netmask":"255.255.255.0","gateway":"192.168.1.1","dns":"192.168.1.1"}
+	}`, messages[0].Data)
+
+	discoveries := emitter.discoveriesOf("meta")
+	require.Len(t, discoveries, 5)
+	for _, d := range discoveries {
+		assert.Equal(t, "ezr/device1/0/state/meta", d.StateTopic)
+		assert.Equal(t, "diagnostic", d.EntityCategory)
+	}
+	assert.Equal(t, "device1-error_count", discoveries[0].UniqueID)
+}
+
+func TestMetaOf_RedactsSecrets(t *testing.T) {
+	password, expert, empty := "secret", "1234", ""
+	meta := metaOf(&transport.Device{
+		Cloud: &transport.Cloud{Password: &password},
+		Code:  &transport.Code{Expert: &expert},
+	})
+
+	data, err := json.Marshal(meta)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), password)
+	assert.NotContains(t, string(data), expert)
+	assert.Equal(t, api.Redacted, *meta.Cloud.Password)
+	assert.Equal(t, api.Redacted, *meta.ExpertCode)
+
+	meta = metaOf(&transport.Device{Cloud: &transport.Cloud{Password: &empty}})
+	assert.Empty(t, *meta.Cloud.Password)
+}
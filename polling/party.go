@@ -0,0 +1,52 @@
+package polling
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/api"
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
+
+// party is a running party of a room, counted down between polls.
+type party struct {
+	rm        room
+	until     time.Time
+	remaining int
+}
+
+// trackParty starts or stops counting down the party of a room as reported
+// by the controller.
+func (r *Poller) trackParty(rm room, h *transport.HeatArea) {
+	if h.Party == nil || *h.Party == 0 || h.PartyRemainingTime == nil || *h.PartyRemainingTime <= 0 {
+		delete(r.parties, rm.nr)
+		return
+	}
+	r.parties[rm.nr] = party{
+		rm:        rm,
+		until:     time.Now().Add(time.Duration(*h.PartyRemainingTime) * time.Minute),
+		remaining: *h.PartyRemainingTime,
+	}
+}
+
+// countdown publishes the remaining time of running parties. Parties which
+// ran out are published as ended, the next poll corrects this if the
+// controller disagrees.
+func (r *Poller) countdown(ctx context.Context) {
+	for nr, p := range r.parties {
+		remaining := max(int(math.Ceil(time.Until(p.until).Minutes())), 0)
+		if remaining == p.remaining {
+			continue
+		}
+		r.sendMsg(ctx, p.rm, "party_remaining_time", strconv.Itoa(remaining))
+		if remaining == 0 {
+			r.sendMsg(ctx, p.rm, "party", api.OnOff(0))
+			delete(r.parties, nr)
+			continue
+		}
+		p.remaining = remaining
+		r.parties[nr] = p
+	}
+}
@@ -0,0 +1,106 @@
+package polling
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport"
+	"github.com/chrishrb/ezr2mqtt/transport/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller_PollOnce_PartyAndPresence(t *testing.T) {
+	client := mock.NewMockClient()
+	err := client.Send(&transport.Message{Device: transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Nr: ptr(1), Party: ptr(1), PartyRemainingTime: ptr(90), Presence: ptr(1)}},
+	}})
+	require.NoError(t, err)
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	data := make(map[string]string)
+	for _, msg := range emitter.messagesOf("party", "party_remaining_time", "presence") {
+		data[emitter.StateTopic("device1", msg)] = msg.Data
+	}
+	assert.Equal(t, map[string]string{
+		"ezr/device1/1/state/party":                "on",
+		"ezr/device1/1/state/party_remaining_time": "90",
+		"ezr/device1/1/state/presence":             "on",
+		"ezr/device1/2/state/party":                "off",
+		"ezr/device1/2/state/party_remaining_time": "0",
+		"ezr/device1/2/state/presence":             "off",
+	}, data)
+
+	discoveries := emitter.discoveriesOf("party", "party_remaining_time", "presence")
+	require.Len(t, discoveries, 6)
+	assert.Equal(t, "ezr/device1/1/set/party", discoveries[1].CommandTopic)
+	assert.Equal(t, "min", discoveries[1].UnitOfMeasurement)
+}
+
+func TestPoller_Countdown(t *testing.T) {
+	client := mock.NewMockClient()
+	err := client.Send(&transport.Message{Device: transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Nr: ptr(1), Party: ptr(1), PartyRemainingTime: ptr(90)}},
+	}})
+	require.NoError(t, err)
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+	require.Contains(t, poller.parties, 1)
+	polled := len(emitter.emitted())
+
+	// nothing changed yet
+	poller.countdown(context.Background())
+	assert.Len(t, emitter.emitted(), polled)
+
+	p := poller.parties[1]
+	p.until = p.until.Add(-30 * time.Minute)
+	poller.parties[1] = p
+	poller.countdown(context.Background())
+	require.Len(t, emitter.emitted(), polled+1)
+	assert.Equal(t, "party_remaining_time", emitter.emitted()[polled].Type)
+	assert.Equal(t, "60", emitter.emitted()[polled].Data)
+
+	// ran out
+	p = poller.parties[1]
+	p.until = time.Now().Add(-time.Second)
+	poller.parties[1] = p
+	poller.countdown(context.Background())
+	require.Len(t, emitter.emitted(), polled+3)
+	assert.Equal(t, "0", emitter.emitted()[polled+1].Data)
+	assert.Equal(t, "party", emitter.emitted()[polled+2].Type)
+	assert.Equal(t, "off", emitter.emitted()[polled+2].Data)
+	assert.Empty(t, poller.parties)
+}
+
+func TestPoller_PollOnce_ChildLock(t *testing.T) {
+	client := mock.NewMockClient()
+	err := client.Send(&transport.Message{Device: transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Nr: ptr(1), IsLocked: ptr(1), LockCode: ptr("9876")}},
+	}})
+	require.NoError(t, err)
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	// the lock isn't available in the bedroom
+	messages := emitter.messagesOf("child_lock")
+	require.Len(t, messages, 1)
+	assert.Equal(t, 1, messages[0].Room)
+	assert.Equal(t, "on", messages[0].Data)
+	for _, m := range emitter.emitted() {
+		assert.NotContains(t, m.Data, "9876", m.Type)
+	}
+
+	discoveries := emitter.discoveriesOf("child_lock")
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "device1-1-child_lock", discoveries[0].UniqueID)
+	assert.Equal(t, "ezr/device1/1/set/child_lock", discoveries[0].CommandTopic)
+}
@@ -2,9 +2,12 @@ package polling
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chrishrb/ezr2mqtt/api"
@@ -12,12 +15,71 @@ import (
 	"github.com/chrishrb/ezr2mqtt/transport"
 )
 
+// room identifies a heat area in topics
+type room struct {
+	nr   int
+	slug string
+}
+
 type Poller struct {
-	name     string
-	client   transport.Client
-	emitter  api.Emitter
-	runEvery time.Duration
-	store    store.Store
+	name            string
+	client          transport.Client
+	emitter         api.Emitter
+	runEvery        time.Duration
+	store           store.Store
+	temperatureStep float64
+	refreshDelay    time.Duration
+	activeEvery     time.Duration
+	activeFor       time.Duration
+	maxBackoff      time.Duration
+	jitter          time.Duration
+	retryDelay      time.Duration
+
+	// layout and entities of the last discovery and running parties, only
+	// used by the poll loop
+	layout         string
+	entities       map[entity]struct{}
+	parties        map[int]party
+	countdownEvery time.Duration
+
+	// maxClockDrift enables clock sync if positive, the clock is synced
+	// at most every clockSyncEvery
+	maxClockDrift     time.Duration
+	clockSyncEvery    time.Duration
+	lastClockSync     time.Time
+	zoneMismatch      bool
+	now               func() time.Time
+	installerSettings bool
+
+	intervalMu  sync.Mutex
+	activeUntil time.Time
+	failures    int
+	discovered  bool
+
+	wg sync.WaitGroup
+
+	refreshMu    sync.Mutex
+	refreshAll   bool
+	refreshRooms map[int]struct{}
+	refreshCh    chan struct{}
+}
+
+type Opt func(*Poller)
+
+// WithTemperatureStep sets the step of temperature targets announced in
+// the discovery.
+func WithTemperatureStep(step float64) Opt {
+	return func(p *Poller) {
+		p.temperatureStep = step
+	}
+}
+
+// WithRefreshDelay sets how long a refresh waits for the device to apply a
+// change and for further refreshes to coalesce with.
+func WithRefreshDelay(delay time.Duration) Opt {
+	return func(p *Poller) {
+		p.refreshDelay = delay
+	}
 }
 
 func NewPoller(
@@ -26,128 +88,312 @@ func NewPoller(
 	emitter api.Emitter,
 	runEvery time.Duration,
 	store store.Store,
+	opts ...Opt,
 ) *Poller {
-	return &Poller{
-		name:     name,
-		client:   client,
-		emitter:  emitter,
-		runEvery: runEvery,
-		store:    store,
+	p := &Poller{
+		name:            name,
+		client:          client,
+		emitter:         emitter,
+		runEvery:        runEvery,
+		store:           store,
+		temperatureStep: 0.5,
+		refreshDelay:    time.Second,
+		retryDelay:      5 * time.Second,
+		parties:         make(map[int]party),
+		countdownEvery:  time.Minute,
+		clockSyncEvery:  time.Hour,
+		now:             time.Now,
+		refreshRooms:    make(map[int]struct{}),
+		refreshCh:       make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
+// Run polls the device until ctx is done. The first poll runs right away
+// and discovers the device, it is retried with backoff until the device is
+// reachable.
 func (r *Poller) Run(ctx context.Context) {
-	go r.pollOnce(ctx)
-	go r.pollPeriodic(ctx)
+	r.wg.Go(func() {
+		r.pollPeriodic(ctx)
+	})
 }
 
-func (r *Poller) pollOnce(ctx context.Context) {
-	res, err := r.client.Connect()
-	if err != nil {
-		slog.Error("error sending message to static endpoint", "error", err)
+// Wait blocks until the poller stopped after ctx passed to Run is done.
+func (r *Poller) Wait() {
+	r.wg.Wait()
+}
+
+// Refresh polls the device out of band after the refresh delay and
+// publishes the state of the given rooms, or of all rooms if none are
+// given. Refreshes requested before the poll starts are coalesced.
+func (r *Poller) Refresh(rooms ...int) {
+	r.activate()
+
+	r.refreshMu.Lock()
+	if len(rooms) == 0 {
+		r.refreshAll = true
+	}
+	for _, nr := range rooms {
+		r.refreshRooms[nr] = struct{}{}
+	}
+	r.refreshMu.Unlock()
+
+	select {
+	case r.refreshCh <- struct{}{}:
+	default:
+		// a refresh is pending already
 	}
+}
 
-	// Store device ID
-	r.store.SetID(r.name, *res.Device.ID)
+// takeRefreshRooms returns the rooms to refresh, nil means all rooms.
+func (r *Poller) takeRefreshRooms() map[int]struct{} {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
 
-	// Build json meta data
-	if res.Device.HeatAreas != nil {
-		for _, h := range *res.Device.HeatAreas {
-			roomName := removeUmlauts(*h.Name)
-			roomNumber := *h.Nr
-
-			r.emitter.EmitHADiscovery(ctx, api.HAComponentNumber, api.HASensorDiscovery{
-				Name:     fmt.Sprintf("%s Temperature Target", roomName),
-				UniqueID: fmt.Sprintf("%s-%s-temperature_target", r.name, strings.ToLower(roomName)),
-				// TODO: refactor
-				StateTopic:        fmt.Sprintf("%s/%s/%d/state/temperature_target", "ezr", r.name, roomNumber),
-				UnitOfMeasurement: "°C",
-				DeviceClass:       "temperature",
-				StateClass:        "measurement",
-				// TODO: refactor
-				CommandTopic: fmt.Sprintf("%s/%s/%d/set/temperature_target", "ezr", r.name, roomNumber),
-				Minimum:      *h.TTargetMin,
-				Maximum:      *h.TTargetMax,
-				Step:         0.5,
-				Mode:         "slider",
-				Device: &api.HADevice{
-					Identifiers: []string{*res.Device.ID},
-					Name:        *res.Device.Name,
-				},
-			})
-
-			r.emitter.EmitHADiscovery(ctx, api.HAComponentSensor, api.HASensorDiscovery{
-				Name:     fmt.Sprintf("%s Temperature Actual", roomName),
-				UniqueID: fmt.Sprintf("%s-%s-temperature_actual", r.name, strings.ToLower(roomName)),
-				// TODO: refactor
-				StateTopic:        fmt.Sprintf("%s/%s/%d/state/temperature_actual", "ezr", r.name, roomNumber),
-				UnitOfMeasurement: "°C",
-				DeviceClass:       "temperature",
-				StateClass:        "measurement",
-				Device: &api.HADevice{
-					Identifiers: []string{*res.Device.ID},
-					Name:        *res.Device.Name,
-				},
-			})
-
-			r.emitter.EmitHADiscovery(ctx, api.HAComponentSelect, api.HASensorDiscovery{
-				Name:     fmt.Sprintf("%s Heatarea Mode", roomName),
-				UniqueID: fmt.Sprintf("%s-%s-heatarea_mode", r.name, strings.ToLower(roomName)),
-				// TODO: refactor
-				StateTopic: fmt.Sprintf("%s/%s/%d/state/heatarea_mode", "ezr", r.name, roomNumber),
-				// TODO: refactor
-				CommandTopic: fmt.Sprintf("%s/%s/%d/set/heatarea_mode", "ezr", r.name, roomNumber),
-				Options: []string{
-					"auto",
-					"day",
-					"night",
-				},
-				Device: &api.HADevice{
-					Identifiers: []string{*res.Device.ID},
-					Name:        *res.Device.Name,
-				},
-			})
-		}
+	rooms := r.refreshRooms
+	if r.refreshAll {
+		rooms = nil
 	}
+	r.refreshAll = false
+	r.refreshRooms = make(map[int]struct{})
+	return rooms
 }
 
 func (r *Poller) pollPeriodic(ctx context.Context) {
+	timer := time.NewTimer(r.nextInterval())
+	defer timer.Stop()
+	countdown := time.NewTicker(r.countdownEvery)
+	defer countdown.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			slog.Info("shutting down run periodic")
 			return
-		case <-time.After(r.runEvery):
-			res, err := r.client.Connect()
-			if err != nil {
-				slog.Error("error sending periodic message to static endpoint", "error", err)
+		case <-timer.C:
+			r.poll(ctx, nil)
+			timer.Reset(r.nextInterval())
+		case <-countdown.C:
+			r.countdown(ctx)
+		case <-r.refreshCh:
+			select {
+			case <-ctx.Done():
+				slog.Info("shutting down run periodic")
+				return
+			case <-time.After(r.refreshDelay):
+			}
+			// refreshes requested meanwhile are covered by this poll
+			select {
+			case <-r.refreshCh:
+			default:
+			}
+			rooms := r.takeRefreshRooms()
+			if rooms != nil && len(rooms) == 0 {
+				// covered by the previous poll already
 				continue
 			}
+			r.poll(ctx, rooms)
+			// the device was just polled and the interval may have changed
+			timer.Reset(r.nextInterval())
+		}
+	}
+}
+
+// poll fetches the state of the device and publishes it for the given
+// rooms, or for all rooms if rooms is nil.
+func (r *Poller) poll(ctx context.Context, rooms map[int]struct{}) {
+	res, err := r.client.Connect()
+	r.recordPoll(err == nil)
+	if err != nil {
+		slog.Error("error sending periodic message to static endpoint", "device_name", r.name, "error", err)
+		return
+	}
+
+	rediscover := r.isDiscovered() && layoutOf(&res.Device) != r.layout
+	if rediscover {
+		slog.Info("device layout changed, discovering again", "device_name", r.name)
+	}
+	if !r.isDiscovered() || rediscover {
+		err = r.discover(ctx, &res.Device)
+		if err != nil {
+			slog.Error("error discovering device", "device_name", r.name, "error", err)
+			r.recordPoll(false)
+			return
+		}
+		r.setDiscovered(true)
+		rooms = nil
+	}
+
+	if changed(r.store.GetDevice(r.name), &res.Device) {
+		r.activate()
+	}
+	r.store.SetDevice(r.name, &res.Device)
 
-			if res.Device.HeatAreas != nil {
-				for _, h := range *res.Device.HeatAreas {
-					roomNumber := *h.Nr
+	_, ok := rooms[api.DeviceRoom]
+	publishDevice := rooms == nil || ok
+	if publishDevice {
+		r.publishDevice(ctx, &res.Device)
+	}
+	r.checkClock(ctx, &res.Device, publishDevice)
 
-					r.sendMsg(ctx, roomNumber, "temperature_target", api.FormatFloat(*h.TTarget))
-					r.sendMsg(ctx, roomNumber, "temperature_actual", api.FormatFloat(*h.TActual))
+	if res.Device.HeatAreas != nil {
+		valves := valvesOf(&res.Device)
+		for _, h := range *res.Device.HeatAreas {
+			if h.Nr == nil || h.Name == nil {
+				continue
+			}
+			if _, ok := rooms[*h.Nr]; rooms != nil && !ok {
+				continue
+			}
+			rm := room{nr: *h.Nr, slug: api.Slug(*h.Name)}
 
-					mode, err := getHeatAreaMode(*h.Mode)
-					if err == nil {
-						r.sendMsg(ctx, roomNumber, "heatarea_mode", mode)
-					} else {
-						slog.Error("error getting heat area mode", "error", err)
-					}
+			r.sendMsg(ctx, rm, "room_name", *h.Name)
+			if h.TTarget != nil {
+				r.sendMsg(ctx, rm, "temperature_target", api.FormatFloat(*h.TTarget))
+			}
+			if h.TActual != nil {
+				r.sendMsg(ctx, rm, "temperature_actual", api.FormatFloat(*h.TActual))
+			}
+			if h.HasExtSensor() {
+				r.sendMsg(ctx, rm, "temperature_external", api.FormatFloat(*h.TActualExt))
+			}
+			if h.Offset != nil {
+				r.sendMsg(ctx, rm, "temperature_offset", api.FormatFloat(*h.Offset))
+			}
+			if h.Mode != nil {
+				mode, err := getHeatAreaMode(*h.Mode)
+				if err == nil {
+					r.sendMsg(ctx, rm, "heatarea_mode", mode)
+				} else {
+					slog.Error("error getting heat area mode", "error", err)
+				}
+			}
+			if v, ok := valves[rm.nr]; ok {
+				active := "off"
+				if v.active {
+					active = "on"
+				}
+				r.sendMsg(ctx, rm, "heating_active", active)
+				r.sendMsg(ctx, rm, "valve_opening", strconv.Itoa(v.opening))
+				r.sendMsg(ctx, rm, "hvac_action", v.action(isCooling(&res.Device)))
+			}
+			for _, sp := range setpointsOf(&h) {
+				if sp.value != nil {
+					r.sendMsg(ctx, rm, sp.typ, api.FormatFloat(*sp.value))
 				}
 			}
+			if h.Party != nil {
+				r.sendMsg(ctx, rm, "party", api.OnOff(*h.Party))
+			}
+			if h.PartyRemainingTime != nil {
+				r.sendMsg(ctx, rm, "party_remaining_time", strconv.Itoa(*h.PartyRemainingTime))
+			}
+			if h.Presence != nil {
+				r.sendMsg(ctx, rm, "presence", api.OnOff(*h.Presence))
+			}
+			// the lock code is never published
+			if h.Lockable() {
+				r.sendMsg(ctx, rm, "child_lock", api.OnOff(*h.IsLocked))
+			}
+			r.trackParty(rm, &h)
+			if h.ProgramWeek != nil {
+				r.sendMsg(ctx, rm, "program_week", strconv.Itoa(*h.ProgramWeek))
+			}
+			if h.ProgramWeekend != nil {
+				r.sendMsg(ctx, rm, "program_weekend", strconv.Itoa(*h.ProgramWeekend))
+			}
+		}
+	}
+
+	r.publishIODevices(ctx, &res.Device, rooms)
+}
+
+// publishDevice publishes the state which applies to the whole device.
+func (r *Poller) publishDevice(ctx context.Context, device *transport.Device) {
+	rm := room{nr: api.DeviceRoom}
+
+	if device.Name != nil {
+		r.sendMsg(ctx, rm, "device_name", *device.Name)
+	}
+	r.publishChangeover(ctx, device)
+	r.publishInstallerSettings(ctx, device)
+
+	data, err := json.Marshal(metaOf(device))
+	if err == nil {
+		r.sendMsg(ctx, rm, "meta", string(data))
+	} else {
+		slog.Error("error encoding meta", "error", err)
+	}
+
+	if device.Vacation != nil {
+		data, err := json.Marshal(vacationOf(device))
+		if err == nil {
+			r.sendMsg(ctx, rm, "vacation", string(data))
+		} else {
+			slog.Error("error encoding vacation", "error", err)
+		}
+	}
+
+	if device.Program != nil {
+		data, err := json.Marshal(programsOf(device.Program))
+		if err == nil {
+			r.sendMsg(ctx, rm, "programs", string(data))
+		} else {
+			slog.Error("error encoding programs", "error", err)
+		}
+	}
+}
+
+// programsOf returns the switching times in use of each program.
+func programsOf(program *transport.Program) api.Programs {
+	res := make(api.Programs)
+	for nr, slots := range program.Slots() {
+		res[nr] = []api.Slot{}
+		for _, s := range slots {
+			if !s.Unused() {
+				res[nr] = append(res[nr], api.Slot{Start: *s.Start, End: *s.End})
+			}
 		}
 	}
+	return res
 }
 
-func (r *Poller) sendMsg(ctx context.Context, room int, t string, data string) {
+// vacationOf converts the vacation reported by the controller. Dates the
+// controller reports in an unexpected format are left out.
+func vacationOf(device *transport.Device) api.Vacation {
+	v := device.Vacation
+	res := api.Vacation{Temperature: device.THeatVacation}
+	if v.State != nil {
+		enabled := *v.State == 1
+		res.Enabled = &enabled
+	}
+	res.Start = formatVacationTime(v.StartDate, v.StartTime)
+	res.End = formatVacationTime(v.EndDate, v.EndTime)
+	return res
+}
+
+func formatVacationTime(date, clock *string) *string {
+	if date == nil || clock == nil {
+		return nil
+	}
+	t, err := transport.ParseDateTime(*date, *clock)
+	if err != nil {
+		slog.Error("error parsing vacation date", "date", *date, "time", *clock, "error", err)
+		return nil
+	}
+	s := t.Format(api.VacationTimeLayout)
+	return &s
+}
+
+func (r *Poller) sendMsg(ctx context.Context, rm room, t string, data string) {
 	msg := &api.Message{
-		Room: room,
-		Type: t,
-		Data: data,
+		Room:     rm.nr,
+		RoomName: rm.slug,
+		Type:     t,
+		Data:     data,
 	}
 	err := r.emitter.Emit(ctx, r.name, msg)
 	if err != nil {
@@ -155,6 +401,14 @@ func (r *Poller) sendMsg(ctx context.Context, room int, t string, data string) {
 	}
 }
 
+func (r *Poller) stateTopic(rm room, t string) string {
+	return r.emitter.StateTopic(r.name, &api.Message{Room: rm.nr, RoomName: rm.slug, Type: t})
+}
+
+func (r *Poller) commandTopic(rm room, t string) string {
+	return r.emitter.CommandTopic(r.name, &api.Message{Room: rm.nr, RoomName: rm.slug, Type: t})
+}
+
 func getHeatAreaMode(mode int) (string, error) {
 	switch mode {
 	case 0:
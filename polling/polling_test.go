@@ -2,23 +2,153 @@ package polling
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/chrishrb/ezr2mqtt/api"
 	"github.com/chrishrb/ezr2mqtt/store"
+	"github.com/chrishrb/ezr2mqtt/transport"
 	"github.com/chrishrb/ezr2mqtt/transport/mock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+type fakeEmitter struct {
+	sync.Mutex
+	byName      bool
+	names       []string
+	messages    []*api.Message
+	discoveries []api.HASensorDiscovery
+	components  []api.HAComponent
+	removed     []string
+}
+
+func (e *fakeEmitter) Emit(ctx context.Context, name string, message *api.Message) error {
+	e.Lock()
+	defer e.Unlock()
+	e.names = append(e.names, name)
+	e.messages = append(e.messages, message)
+	return nil
+}
+
+func (e *fakeEmitter) EmitHADiscovery(ctx context.Context, component api.HAComponent, message api.HASensorDiscovery) error {
+	e.Lock()
+	defer e.Unlock()
+	e.discoveries = append(e.discoveries, message)
+	e.components = append(e.components, component)
+	return nil
+}
+
+func (e *fakeEmitter) RemoveHADiscovery(ctx context.Context, component api.HAComponent, uniqueID string) error {
+	e.Lock()
+	defer e.Unlock()
+	e.removed = append(e.removed, uniqueID)
+	return nil
+}
+
+func (e *fakeEmitter) StateTopic(name string, message *api.Message) string {
+	return fmt.Sprintf("ezr/%s/%s/state/%s", name, e.room(message), message.Type)
+}
+
+func (e *fakeEmitter) CommandTopic(name string, message *api.Message) string {
+	return fmt.Sprintf("ezr/%s/%s/set/%s", name, e.room(message), message.Type)
+}
+
+func (e *fakeEmitter) room(message *api.Message) string {
+	if e.byName && message.RoomName != "" {
+		return message.RoomName
+	}
+	return strconv.Itoa(message.Room)
+}
+
+// countingClient counts the polls of the wrapped client, which fail while
+// err is set
+type countingClient struct {
+	transport.Client
+	mu    sync.Mutex
+	polls int
+	err   error
+}
+
+func (c *countingClient) Connect() (*transport.Message, error) {
+	c.mu.Lock()
+	c.polls++
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return c.Client.Connect()
+}
+
+func (c *countingClient) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *countingClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.polls
+}
+
+func (e *fakeEmitter) emitted() []*api.Message {
+	e.Lock()
+	defer e.Unlock()
+	return append([]*api.Message(nil), e.messages...)
+}
+
+// basicTypes are the types published for every heat area
+var basicTypes = []string{"temperature_target", "temperature_actual", "heatarea_mode"}
+
+// messagesOf returns the emitted messages of the given types
+func (e *fakeEmitter) messagesOf(types ...string) []*api.Message {
+	var res []*api.Message
+	for _, msg := range e.emitted() {
+		if slices.Contains(types, msg.Type) {
+			res = append(res, msg)
+		}
+	}
+	return res
+}
+
+// discoveriesOf returns the discoveries of entities with the state topic of
+// the given types
+func (e *fakeEmitter) discoveriesOf(types ...string) []api.HASensorDiscovery {
+	e.Lock()
+	defer e.Unlock()
+	var res []api.HASensorDiscovery
+	for _, d := range e.discoveries {
+		if slices.Contains(types, d.StateTopic[strings.LastIndex(d.StateTopic, "/")+1:]) {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// removedOf returns the removed entities of the given types
+func (e *fakeEmitter) removedOf(types ...string) []string {
+	e.Lock()
+	defer e.Unlock()
+	var res []string
+	for _, uniqueID := range e.removed {
+		if slices.ContainsFunc(types, func(t string) bool { return strings.HasSuffix(uniqueID, "-"+t) }) {
+			res = append(res, uniqueID)
+		}
+	}
+	return res
+}
+
 func TestNewPoller(t *testing.T) {
 	client := mock.NewMockClient()
-	emitterCalled := false
-	emitter := api.EmitterFunc(func(ctx context.Context, id string, message *api.Message) error {
-		emitterCalled = true
-		return nil
-	})
+	emitter := &fakeEmitter{}
 	store := store.NewInMemoryStore()
 	runEvery := 5 * time.Second
 
@@ -29,71 +159,65 @@ func TestNewPoller(t *testing.T) {
 	assert.Equal(t, client, poller.client)
 	assert.Equal(t, runEvery, poller.runEvery)
 	assert.Equal(t, store, poller.store)
-	assert.False(t, emitterCalled) // Should not be called during construction
+	assert.Empty(t, emitter.emitted()) // Should not be called during construction
 }
 
 func TestPoller_PollOnce_Success(t *testing.T) {
 	client := mock.NewMockClient()
 	store := store.NewInMemoryStore()
 	deviceName := "test-device"
-
-	var emittedMessages []*api.Message
-	var emittedIDs []string
-
-	emitter := api.EmitterFunc(func(ctx context.Context, id string, message *api.Message) error {
-		emittedIDs = append(emittedIDs, id)
-		emittedMessages = append(emittedMessages, message)
-		return nil
-	})
+	emitter := &fakeEmitter{}
 
 	poller := NewPoller(deviceName, client, emitter, 1*time.Hour, store)
+	poller.poll(context.Background(), nil)
 
-	ctx := context.Background()
-	poller.pollOnce(ctx)
-
-	// Give it a moment to complete
-	time.Sleep(100 * time.Millisecond)
-
-	// Verify device ID was stored
+	// Verify device ID and state were stored
 	id := store.GetID(deviceName)
 	assert.NotNil(t, id)
 	assert.Equal(t, "MOCK-12345", *id)
+	require.NotNil(t, store.GetDevice(deviceName))
+	assert.Equal(t, "Mock Device", *store.GetDevice(deviceName).Name)
+
+	// Three entities per heat area
+	discoveries := emitter.discoveriesOf(basicTypes...)
+	require.Len(t, discoveries, 6)
+	assert.Equal(t, "Living Room Temperature Target", discoveries[0].Name)
+	assert.Equal(t, "ezr/test-device/1/state/temperature_target", discoveries[0].StateTopic)
+	assert.Equal(t, "ezr/test-device/1/set/temperature_target", discoveries[0].CommandTopic)
+	assert.Equal(t, "ezr/test-device/2/set/heatarea_mode", discoveries[5].CommandTopic)
+	assert.Equal(t, []string{"MOCK-12345"}, discoveries[0].Device.Identifiers)
+	assert.Equal(t, 0.5, discoveries[0].Step)
+}
 
-	// Verify meta message was emitted
-	assert.Len(t, emittedMessages, 1)
-	assert.Equal(t, deviceName, emittedIDs[0])
-	assert.Equal(t, "meta", emittedMessages[0].Type)
-	assert.Equal(t, 0, emittedMessages[0].Room)
+func TestPoller_PollOnce_TemperatureStep(t *testing.T) {
+	emitter := &fakeEmitter{}
 
-	// Verify meta data structure
-	var metaData api.ClimateDiscovery
-	err := json.Unmarshal([]byte(emittedMessages[0].Data), &metaData)
-	assert.NoError(t, err)
-	assert.Equal(t, "Mock Device", metaData.Name)
-	assert.Equal(t, "MOCK-12345", metaData.ID)
-	assert.Equal(t, "EZR", metaData.Type)
-	assert.Len(t, metaData.Rooms, 2)
+	poller := NewPoller("test-device", mock.NewMockClient(), emitter, 1*time.Hour, store.NewInMemoryStore(), WithTemperatureStep(0.2))
+	poller.poll(context.Background(), nil)
 
-	// Verify room data
-	assert.Equal(t, 1, metaData.Rooms[0].ID)
-	assert.Equal(t, "Living Room", metaData.Rooms[0].Name)
-	assert.Equal(t, 2, metaData.Rooms[1].ID)
-	assert.Equal(t, "Bedroom", metaData.Rooms[1].Name)
+	require.NotEmpty(t, emitter.discoveries)
+	assert.Equal(t, 0.2, emitter.discoveries[0].Step)
+}
+
+func TestPoller_PollOnce_RoomTopicsByName(t *testing.T) {
+	client := mock.NewMockClient()
+	emitter := &fakeEmitter{byName: true}
+
+	poller := NewPoller("test-device", client, emitter, 1*time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	discoveries := emitter.discoveriesOf(basicTypes...)
+	require.Len(t, discoveries, 6)
+	assert.Equal(t, "ezr/test-device/living_room/state/temperature_target", discoveries[0].StateTopic)
+	assert.Equal(t, "ezr/test-device/living_room/set/temperature_target", discoveries[0].CommandTopic)
+	assert.Equal(t, "ezr/test-device/bedroom/set/heatarea_mode", discoveries[5].CommandTopic)
 }
 
 func TestPoller_PollPeriodic_EmitsMessages(t *testing.T) {
 	client := mock.NewMockClient()
 	store := store.NewInMemoryStore()
 	deviceName := "test-device"
-
-	var emittedMessages []*api.Message
-	var emittedIDs []string
-
-	emitter := api.EmitterFunc(func(ctx context.Context, id string, message *api.Message) error {
-		emittedIDs = append(emittedIDs, id)
-		emittedMessages = append(emittedMessages, message)
-		return nil
-	})
+	emitter := &fakeEmitter{}
 
 	// Use a very short polling interval for testing
 	poller := NewPoller(deviceName, client, emitter, 50*time.Millisecond, store)
@@ -104,30 +228,36 @@ func TestPoller_PollPeriodic_EmitsMessages(t *testing.T) {
 	poller.pollPeriodic(ctx)
 
 	// Should have emitted messages for at least one poll cycle
-	// Each cycle emits 2 messages per heat area (target and actual)
-	// Mock client has 2 heat areas, so 4 messages per cycle
-	assert.GreaterOrEqual(t, len(emittedMessages), 4)
+	// Each cycle emits 3 messages per heat area (target, actual and mode)
+	// Mock client has 2 heat areas, so 6 messages per cycle
+	emittedMessages := emitter.messagesOf(basicTypes...)
+	assert.GreaterOrEqual(t, len(emittedMessages), 6)
+	assert.NotNil(t, store.GetDevice(deviceName))
 
 	// Verify message types and structure
 	targetFound := false
 	actualFound := false
 	heatareaModeFound := false
 
-	for i, msg := range emittedMessages {
-		assert.Equal(t, deviceName, emittedIDs[i])
+	emitter.Lock()
+	for _, name := range emitter.names {
+		assert.Equal(t, deviceName, name)
+	}
+	emitter.Unlock()
+
+	for _, msg := range emittedMessages {
 		assert.Contains(t, []string{"temperature_target", "temperature_actual", "heatarea_mode"}, msg.Type)
+		assert.Contains(t, []string{"living_room", "bedroom"}, msg.RoomName)
 
 		if msg.Type == "temperature_target" {
 			targetFound = true
-			assert.IsType(t, "19.00", msg.Data)
 		}
 		if msg.Type == "temperature_actual" {
 			actualFound = true
-			assert.IsType(t, "19.00", msg.Data)
 		}
 		if msg.Type == "heatarea_mode" {
 			heatareaModeFound = true
-			assert.IsType(t, "auto", msg.Data)
+			assert.Equal(t, "day", msg.Data)
 		}
 	}
 
@@ -140,11 +270,7 @@ func TestPoller_PollPeriodic_ContextCancellation(t *testing.T) {
 	client := mock.NewMockClient()
 	store := store.NewInMemoryStore()
 
-	emitter := api.EmitterFunc(func(ctx context.Context, id string, message *api.Message) error {
-		return nil
-	})
-
-	poller := NewPoller("device1", client, emitter, 100*time.Millisecond, store)
+	poller := NewPoller("device1", client, &fakeEmitter{}, 100*time.Millisecond, store)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -171,13 +297,7 @@ func TestPoller_PollPeriodic_ContextCancellation(t *testing.T) {
 func TestPoller_Run_StartsPolling(t *testing.T) {
 	client := mock.NewMockClient()
 	store := store.NewInMemoryStore()
-
-	var emittedMessages []*api.Message
-
-	emitter := api.EmitterFunc(func(ctx context.Context, id string, message *api.Message) error {
-		emittedMessages = append(emittedMessages, message)
-		return nil
-	})
+	emitter := &fakeEmitter{}
 
 	poller := NewPoller("device1", client, emitter, 50*time.Millisecond, store)
 
@@ -189,12 +309,11 @@ func TestPoller_Run_StartsPolling(t *testing.T) {
 	// Wait for polling to occur
 	time.Sleep(250 * time.Millisecond)
 
-	// Should have emitted at least the meta message from pollOnce
-	// and some periodic messages
-	assert.Greater(t, len(emittedMessages), 0)
-
-	// First message should be meta
-	assert.Equal(t, "meta", emittedMessages[0].Type)
+	// Should have emitted the discovery and some periodic messages
+	emitter.Lock()
+	defer emitter.Unlock()
+	assert.NotEmpty(t, emitter.discoveries)
+	assert.NotEmpty(t, emitter.messages)
 }
 
 func TestPoller_PollOnce_StoresCorrectDeviceID(t *testing.T) {
@@ -202,16 +321,8 @@ func TestPoller_PollOnce_StoresCorrectDeviceID(t *testing.T) {
 	store := store.NewInMemoryStore()
 	deviceName := "my-device"
 
-	emitter := api.EmitterFunc(func(ctx context.Context, id string, message *api.Message) error {
-		return nil
-	})
-
-	poller := NewPoller(deviceName, client, emitter, 1*time.Hour, store)
-
-	ctx := context.Background()
-	poller.pollOnce(ctx)
-
-	time.Sleep(50 * time.Millisecond)
+	poller := NewPoller(deviceName, client, &fakeEmitter{}, 1*time.Hour, store)
+	poller.poll(context.Background(), nil)
 
 	// Verify the device ID was stored correctly
 	id := store.GetID(deviceName)
@@ -222,13 +333,7 @@ func TestPoller_PollOnce_StoresCorrectDeviceID(t *testing.T) {
 func TestPoller_PollPeriodic_EmitsCorrectData(t *testing.T) {
 	client := mock.NewMockClient()
 	store := store.NewInMemoryStore()
-
-	var emittedMessages []*api.Message
-
-	emitter := api.EmitterFunc(func(ctx context.Context, id string, message *api.Message) error {
-		emittedMessages = append(emittedMessages, message)
-		return nil
-	})
+	emitter := &fakeEmitter{}
 
 	poller := NewPoller("device1", client, emitter, 50*time.Millisecond, store)
 
@@ -243,7 +348,7 @@ func TestPoller_PollPeriodic_EmitsCorrectData(t *testing.T) {
 	room2Target := false
 	room2Actual := false
 
-	for _, msg := range emittedMessages {
+	for _, msg := range emitter.messagesOf(basicTypes...) {
 		if msg.Room == 1 && msg.Type == "temperature_target" {
 			room1Target = true
 			assert.Equal(t, "22.00", msg.Data)
@@ -267,3 +372,392 @@ func TestPoller_PollPeriodic_EmitsCorrectData(t *testing.T) {
 	assert.True(t, room2Target, "Should emit room 2 target temperature")
 	assert.True(t, room2Actual, "Should emit room 2 actual temperature")
 }
+
+func TestPoller_Refresh_PublishesAffectedRooms(t *testing.T) {
+	client := &countingClient{Client: mock.NewMockClient()}
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore(), WithRefreshDelay(50*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go poller.pollPeriodic(ctx)
+
+	// initial poll
+	require.Eventually(t, func() bool { return len(emitter.messagesOf(basicTypes...)) == 6 }, time.Second, 10*time.Millisecond)
+	initial := len(emitter.emitted())
+
+	// coalesced into a single poll
+	poller.Refresh(2)
+	poller.Refresh(2)
+
+	require.Eventually(t, func() bool { return len(emitter.messagesOf(basicTypes...)) == 9 }, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, 2, client.count())
+	for _, msg := range emitter.emitted()[initial:] {
+		assert.Equal(t, 2, msg.Room)
+	}
+}
+
+func TestPoller_Refresh_AllRooms(t *testing.T) {
+	client := &countingClient{Client: mock.NewMockClient()}
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore(), WithRefreshDelay(50*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go poller.pollPeriodic(ctx)
+	require.Eventually(t, func() bool { return len(emitter.messagesOf(basicTypes...)) == 6 }, time.Second, 10*time.Millisecond)
+
+	poller.Refresh(1)
+	poller.Refresh()
+
+	require.Eventually(t, func() bool { return len(emitter.messagesOf(basicTypes...)) == 12 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, client.count())
+}
+
+func TestPoller_DiscoveryRetriesUntilReachable(t *testing.T) {
+	client := &countingClient{Client: mock.NewMockClient(), err: errors.New("connection refused")}
+	emitter := &fakeEmitter{}
+	store := store.NewInMemoryStore()
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store, WithRetryDelay(10*time.Millisecond), WithBackoff(40*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	poller.Run(ctx)
+
+	require.Eventually(t, func() bool { return client.count() >= 3 }, time.Second, 5*time.Millisecond)
+	assert.Nil(t, store.GetID("device1"))
+	assert.Empty(t, emitter.emitted())
+
+	client.setErr(nil)
+
+	require.Eventually(t, func() bool { return store.GetID("device1") != nil }, time.Second, 5*time.Millisecond)
+	assert.Len(t, emitter.discoveriesOf(basicTypes...), 6)
+}
+
+func TestPoller_RediscoversAfterOutage(t *testing.T) {
+	client := &countingClient{Client: mock.NewMockClient()}
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+
+	poller.poll(context.Background(), nil)
+	poller.poll(context.Background(), nil)
+	assert.Len(t, emitter.discoveriesOf(basicTypes...), 6)
+
+	client.setErr(errors.New("timeout"))
+	poller.poll(context.Background(), nil)
+	client.setErr(nil)
+	poller.poll(context.Background(), nil)
+
+	assert.Len(t, emitter.discoveriesOf(basicTypes...), 12)
+}
+
+func TestPoller_RediscoversOnLayoutChange(t *testing.T) {
+	client := mock.NewMockClient()
+	emitter := &fakeEmitter{}
+	store := store.NewInMemoryStore()
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store)
+	poller.poll(context.Background(), nil)
+	require.Len(t, emitter.discoveriesOf(basicTypes...), 6)
+	// the entities keyed on the room name by earlier versions
+	legacy := len(emitter.removed)
+
+	// unchanged
+	poller.poll(context.Background(), nil)
+	assert.Len(t, emitter.discoveriesOf(basicTypes...), 6)
+
+	// limits changed
+	err := client.Send(&transport.Message{Device: transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Nr: ptr(2), TTargetMax: ptr(25.0)}},
+	}})
+	require.NoError(t, err)
+	poller.poll(context.Background(), nil)
+	require.Len(t, emitter.discoveriesOf(basicTypes...), 12)
+	assert.Equal(t, 25.0, emitter.discoveriesOf(basicTypes...)[9].Maximum)
+	assert.Len(t, emitter.removed, legacy)
+
+	// renamed room
+	err = client.Send(&transport.Message{Device: transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Nr: ptr(2), Name: ptr("Guest Room")}},
+	}})
+	require.NoError(t, err)
+	poller.poll(context.Background(), nil)
+	require.Len(t, emitter.discoveriesOf(basicTypes...), 18)
+	assert.Equal(t, "Guest Room Temperature Target", emitter.discoveriesOf(basicTypes...)[15].Name)
+	// unique IDs are keyed on the room number, a renamed room keeps its entities
+	assert.Equal(t, "device1-2-temperature_target", emitter.discoveriesOf(basicTypes...)[15].UniqueID)
+	assert.Len(t, emitter.removed, legacy)
+
+	// swapped controller
+	err = client.Send(&transport.Message{Device: transport.Device{ID: ptr("MOCK-67890")}})
+	require.NoError(t, err)
+	poller.poll(context.Background(), nil)
+	assert.Len(t, emitter.discoveriesOf(basicTypes...), 24)
+	assert.Equal(t, []string{"MOCK-67890"}, emitter.discoveriesOf(basicTypes...)[23].Device.Identifiers)
+	assert.Equal(t, "MOCK-67890", *store.GetID("device1"))
+	assert.Len(t, emitter.removed, legacy)
+}
+
+func TestPoller_Wait(t *testing.T) {
+	poller := NewPoller("device1", mock.NewMockClient(), &fakeEmitter{}, 10*time.Millisecond, store.NewInMemoryStore())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	poller.Run(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		poller.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("poller did not stop")
+	}
+}
+
+func TestPoller_PollOnce_Vacation(t *testing.T) {
+	client := mock.NewMockClient()
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	var vacation *api.Message
+	for _, msg := range emitter.emitted() {
+		if msg.Type == "vacation" {
+			vacation = msg
+		}
+	}
+	require.NotNil(t, vacation)
+	assert.Equal(t, api.DeviceRoom, vacation.Room)
+	assert.JSONEq(t, `{"enabled":false,"start":"2025-01-01T00:00","end":"2025-01-01T00:00","temperature":15}`, vacation.Data)
+
+	emitter.Lock()
+	defer emitter.Unlock()
+	var uniqueIDs []string
+	for _, d := range emitter.discoveries {
+		if d.StateTopic == "ezr/device1/0/state/vacation" {
+			uniqueIDs = append(uniqueIDs, d.UniqueID)
+			assert.Equal(t, "ezr/device1/0/set/vacation", d.CommandTopic)
+		}
+	}
+	assert.Equal(t, []string{"device1-vacation", "device1-vacation_temperature", "device1-vacation_start", "device1-vacation_end"}, uniqueIDs)
+}
+
+func TestPoller_PollOnce_Programs(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", mock.NewMockClient(), emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	programs := emitter.messagesOf("programs")
+	require.Len(t, programs, 1)
+	assert.Equal(t, api.DeviceRoom, programs[0].Room)
+	assert.JSONEq(t, `{
+		"1":[{"start":"06:00","end":"22:00"}],
+		"2":[{"start":"06:00","end":"08:00"},{"start":"17:00","end":"22:00"}]
+	}`, programs[0].Data)
+
+	week := emitter.messagesOf("program_week", "program_weekend")
+	require.Len(t, week, 4)
+	assert.Equal(t, "1", week[0].Data)
+	assert.Equal(t, "2", week[1].Data)
+
+	selects := emitter.discoveriesOf("program_week", "program_weekend")
+	require.Len(t, selects, 4)
+	assert.Equal(t, []string{"1", "2"}, selects[0].Options)
+	assert.Equal(t, "ezr/device1/1/set/program_weekend", selects[1].CommandTopic)
+	require.Len(t, emitter.discoveriesOf("programs"), 1)
+}
+
+func TestPoller_PollOnce_Setpoints(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", mock.NewMockClient(), emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	types := []string{"temperature_heat_day", "temperature_heat_night", "temperature_cool_day", "temperature_cool_night", "temperature_floor_day"}
+	messages := emitter.messagesOf(types...)
+	// the mock device doesn't report the floor temperature
+	require.Len(t, messages, 8)
+	assert.Equal(t, "temperature_heat_day", messages[0].Type)
+	assert.Equal(t, "22.00", messages[0].Data)
+
+	discoveries := emitter.discoveriesOf(types...)
+	require.Len(t, discoveries, 8)
+	assert.Equal(t, "Living Room Heat Day Temperature", discoveries[0].Name)
+	assert.Equal(t, "ezr/device1/1/set/temperature_heat_day", discoveries[0].CommandTopic)
+	assert.Equal(t, 30.0, discoveries[0].Maximum)
+}
+
+func TestPoller_PollOnce_Valves(t *testing.T) {
+	client := mock.NewMockClient()
+	err := client.Send(&transport.Message{Device: transport.Device{
+		HeatCtrls: &[]transport.HeatCtrl{
+			{Nr: ptr(2), ActorPercent: ptr(0)},
+			// a second valve of the living room
+			{Nr: ptr(3), InUse: ptr(1), HeatAreaNr: ptr(1), ActorPercent: ptr(35)},
+			// not in use
+			{Nr: ptr(4), InUse: ptr(0), HeatAreaNr: ptr(2), ActorPercent: ptr(100)},
+		},
+	}})
+	require.NoError(t, err)
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	data := make(map[string]string)
+	for _, msg := range emitter.messagesOf("heating_active", "valve_opening", "hvac_action") {
+		data[emitter.StateTopic("device1", msg)] = msg.Data
+	}
+	assert.Equal(t, map[string]string{
+		"ezr/device1/1/state/heating_active": "on",
+		"ezr/device1/1/state/valve_opening":  "48",
+		"ezr/device1/2/state/heating_active": "off",
+		"ezr/device1/2/state/valve_opening":  "0",
+		"ezr/device1/1/state/hvac_action":    "heating",
+		"ezr/device1/2/state/hvac_action":    "idle",
+	}, data)
+
+	require.Len(t, emitter.discoveriesOf("heating_active", "valve_opening"), 4)
+
+	emitter.Lock()
+	defer emitter.Unlock()
+	var climate *api.HASensorDiscovery
+	for _, d := range emitter.discoveries {
+		if d.UniqueID == "device1-1-climate" {
+			climate = &d
+		}
+	}
+	require.NotNil(t, climate)
+	assert.Equal(t, "ezr/device1/1/state/temperature_actual", climate.CurrentTemperatureTopic)
+	assert.Equal(t, "ezr/device1/1/set/temperature_target", climate.TemperatureCommandTopic)
+	assert.Equal(t, "ezr/device1/1/state/hvac_action", climate.ActionTopic)
+	assert.Equal(t, "ezr/device1/1/set/heatarea_mode", climate.PresetModeCommandTopic)
+}
+
+func TestPoller_PollOnce_TemperatureOffset(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", mock.NewMockClient(), emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	messages := emitter.messagesOf("temperature_offset")
+	require.Len(t, messages, 2)
+	assert.Equal(t, "0.00", messages[0].Data)
+	assert.Equal(t, "-0.50", messages[1].Data)
+
+	discoveries := emitter.discoveriesOf("temperature_offset")
+	require.Len(t, discoveries, 2)
+	assert.Equal(t, "ezr/device1/2/set/temperature_offset", discoveries[1].CommandTopic)
+	assert.Equal(t, -2.0, discoveries[1].Minimum)
+	assert.Equal(t, 2.0, discoveries[1].Maximum)
+}
+
+func TestPoller_PollOnce_TemperatureExternal(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", mock.NewMockClient(), emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	// only the living room has a floor probe
+	messages := emitter.messagesOf("temperature_external")
+	require.Len(t, messages, 1)
+	assert.Equal(t, 1, messages[0].Room)
+	assert.Equal(t, "24.50", messages[0].Data)
+
+	discoveries := emitter.discoveriesOf("temperature_external")
+	require.Len(t, discoveries, 1)
+	assert.Equal(t, "Living Room Temperature External", discoveries[0].Name)
+	assert.Equal(t, "temperature", discoveries[0].DeviceClass)
+}
+
+func TestPoller_PollOnce_Names(t *testing.T) {
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", mock.NewMockClient(), emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	data := make(map[string]string)
+	for _, msg := range emitter.messagesOf("room_name", "device_name") {
+		data[emitter.StateTopic("device1", msg)] = msg.Data
+	}
+	assert.Equal(t, map[string]string{
+		"ezr/device1/0/state/device_name": "Mock Device",
+		"ezr/device1/1/state/room_name":   "Living Room",
+		"ezr/device1/2/state/room_name":   "Bedroom",
+	}, data)
+
+	discoveries := emitter.discoveriesOf("room_name", "device_name")
+	require.Len(t, discoveries, 3)
+	assert.Equal(t, "Living Room Name", discoveries[0].Name)
+	assert.Equal(t, "ezr/device1/1/set/room_name", discoveries[0].CommandTopic)
+	assert.Equal(t, 20.0, discoveries[0].Maximum)
+	assert.Equal(t, "device1-device_name", discoveries[2].UniqueID)
+}
+
+func TestPoller_RenamedRoomKeepsEntities(t *testing.T) {
+	client := mock.NewMockClient()
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+	first := emitter.discoveriesOf(basicTypes...)
+	legacy := len(emitter.removed)
+
+	err := client.Send(&transport.Message{Device: transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Nr: ptr(1), Name: ptr("Wohnzimmer")}},
+	}})
+	require.NoError(t, err)
+	poller.poll(context.Background(), nil)
+
+	// the entities are published again with the new name under the same unique IDs
+	second := emitter.discoveriesOf(basicTypes...)[len(first):]
+	require.Len(t, second, len(first))
+	for i, d := range second {
+		assert.Equal(t, first[i].UniqueID, d.UniqueID)
+		if strings.HasPrefix(d.UniqueID, "device1-1-") {
+			assert.True(t, strings.HasPrefix(d.Name, "Wohnzimmer "), d.Name)
+		}
+	}
+	assert.Len(t, emitter.removed, legacy)
+}
+
+func TestPoller_RemovesLegacyEntities(t *testing.T) {
+	client := mock.NewMockClient()
+	emitter := &fakeEmitter{}
+
+	poller := NewPoller("device1", client, emitter, time.Hour, store.NewInMemoryStore())
+	poller.poll(context.Background(), nil)
+
+	assert.ElementsMatch(t, []string{
+		"device1-living room-temperature_target",
+		"device1-living room-temperature_actual",
+		"device1-living room-heatarea_mode",
+		"device1-living_room-temperature_target",
+		"device1-living_room-temperature_actual",
+		"device1-living_room-heatarea_mode",
+		"device1-bedroom-temperature_target",
+		"device1-bedroom-temperature_actual",
+		"device1-bedroom-heatarea_mode",
+	}, emitter.removedOf(basicTypes...))
+
+	// only removed once
+	err := client.Send(&transport.Message{Device: transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Nr: ptr(2), TTargetMax: ptr(25.0)}},
+	}})
+	require.NoError(t, err)
+	poller.poll(context.Background(), nil)
+	assert.Len(t, emitter.removedOf(basicTypes...), 9)
+}
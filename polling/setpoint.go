@@ -0,0 +1,23 @@
+package polling
+
+import "github.com/chrishrb/ezr2mqtt/transport"
+
+// setpoint is a temperature which defines a mode of a room, e.g. the
+// temperature of the day mode.
+type setpoint struct {
+	typ   string
+	name  string
+	value *float64
+}
+
+// setpointsOf returns the setpoints of the room, value is nil if the
+// controller doesn't report the setpoint.
+func setpointsOf(h *transport.HeatArea) []setpoint {
+	return []setpoint{
+		{typ: "temperature_heat_day", name: "Heat Day Temperature", value: h.THeatDay},
+		{typ: "temperature_heat_night", name: "Heat Night Temperature", value: h.THeatNight},
+		{typ: "temperature_cool_day", name: "Cool Day Temperature", value: h.TCoolDay},
+		{typ: "temperature_cool_night", name: "Cool Night Temperature", value: h.TCoolNight},
+		{typ: "temperature_floor_day", name: "Floor Day Temperature", value: h.TFloorDay},
+	}
+}
@@ -1,20 +1,29 @@
 package store
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/chrishrb/ezr2mqtt/transport"
+)
 
 type Store interface {
 	SetID(name, id string)
 	GetID(name string) *string
+	// SetDevice stores the last known state of a device
+	SetDevice(name string, device *transport.Device)
+	GetDevice(name string) *transport.Device
 }
 
 type InMemoryStore struct {
 	sync.Mutex
-	ids map[string]string
+	ids     map[string]string
+	devices map[string]*transport.Device
 }
 
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
-		ids: make(map[string]string),
+		ids:     make(map[string]string),
+		devices: make(map[string]*transport.Device),
 	}
 }
 
@@ -35,3 +44,17 @@ func (s *InMemoryStore) GetID(name string) *string {
 	}
 	return &id
 }
+
+func (s *InMemoryStore) SetDevice(name string, device *transport.Device) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.devices[name] = device
+}
+
+func (s *InMemoryStore) GetDevice(name string) *transport.Device {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.devices[name]
+}
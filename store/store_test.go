@@ -4,6 +4,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/chrishrb/ezr2mqtt/transport"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -176,6 +177,24 @@ func TestInMemoryStore_ConcurrentReadWrite(t *testing.T) {
 	// No assertion needed - the test passes if there's no race condition
 }
 
+func TestInMemoryStore_SetDevice(t *testing.T) {
+	store := NewInMemoryStore()
+
+	name := "Living Room"
+	device := &transport.Device{
+		HeatAreas: &[]transport.HeatArea{{Name: &name}},
+	}
+	store.SetDevice("device1", device)
+
+	assert.Equal(t, device, store.GetDevice("device1"))
+}
+
+func TestInMemoryStore_GetDevice_NotFound(t *testing.T) {
+	store := NewInMemoryStore()
+
+	assert.Nil(t, store.GetDevice("nonexistent"))
+}
+
 func TestInMemoryStore_Interface(t *testing.T) {
 	// Verify that InMemoryStore implements the Store interface
 	var _ Store = (*InMemoryStore)(nil)
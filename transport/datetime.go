@@ -0,0 +1,35 @@
+package transport
+
+import "time"
+
+const (
+	// DateLayout is the layout of dates used by the controller, e.g. 24.12.2025
+	DateLayout = "02.01.2006"
+	// TimeLayout is the layout of times of day used by the controller, e.g. 18:00
+	TimeLayout = "15:04"
+	// ClockLayout is the layout of the clock of the controller, e.g.
+	// 2025-12-24T18:00:00
+	ClockLayout = "2006-01-02T15:04:05"
+)
+
+// ParseDateTime parses a date and time of day as reported by the controller.
+// The controller has no notion of time zones, so the result is in UTC.
+func ParseDateTime(date, clock string) (time.Time, error) {
+	return time.ParseInLocation(DateLayout+" "+TimeLayout, date+" "+clock, time.UTC)
+}
+
+// ParseClock parses the clock of the controller. The controller runs on local
+// time without a time zone, so the result is in loc.
+func ParseClock(v string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(ClockLayout, v, loc)
+}
+
+// Clock returns the date and time and the day of week, 1 being Monday, to set
+// the clock of the controller to t.
+func Clock(t time.Time) (dateTime string, dayOfWeek int) {
+	dayOfWeek = int(t.Weekday())
+	if dayOfWeek == 0 {
+		dayOfWeek = 7
+	}
+	return t.Format(ClockLayout), dayOfWeek
+}
@@ -0,0 +1,61 @@
+package transport
+
+// MaxPartyDuration is the longest party in minutes accepted by the
+// controller.
+const MaxPartyDuration = 24 * 60
+
+const (
+	// MaxOffset is the largest correction of the room temperature in K
+	// accepted by the controller, in either direction.
+	MaxOffset = 2.0
+	// OffsetStep is the resolution of the correction of the room
+	// temperature.
+	OffsetStep = 0.1
+)
+
+// MaxNameLength is the longest name of a heat area or device in characters
+// accepted by the controller.
+const MaxNameLength = 20
+
+// Ranges of the installer settings accepted by the bridge, the controller
+// doesn't report them.
+const (
+	MinAntifreezeTemp = 0.0
+	MaxAntifreezeTemp = 15.0
+	// MaxEcoDiff is the largest reduction of the temperature in K while
+	// the eco input is active.
+	MaxEcoDiff = 10.0
+	// MaxFirstOpenTime is the longest time in minutes valves are opened on
+	// their first use.
+	MaxFirstOpenTime = 60
+)
+
+// TargetRange returns the lowest minimum and highest maximum temperature
+// target of all heat areas, nil if no heat area reports one.
+func (d *Device) TargetRange() (minimum, maximum *float64) {
+	if d.HeatAreas == nil {
+		return nil, nil
+	}
+	for _, h := range *d.HeatAreas {
+		if h.TTargetMin != nil && (minimum == nil || *h.TTargetMin < *minimum) {
+			minimum = h.TTargetMin
+		}
+		if h.TTargetMax != nil && (maximum == nil || *h.TTargetMax > *maximum) {
+			maximum = h.TTargetMax
+		}
+	}
+	return minimum, maximum
+}
+
+// Lockable reports whether the thermostats of the heat area can be locked.
+// Controllers which don't report LockAvailable allow it if they report the
+// lock state.
+func (h *HeatArea) Lockable() bool {
+	return h.IsLocked != nil && (h.LockAvailable == nil || *h.LockAvailable != 0)
+}
+
+// HasExtSensor reports whether a floor probe or external sensor is connected
+// to the heat area.
+func (h *HeatArea) HasExtSensor() bool {
+	return h.SensorExt != nil && *h.SensorExt != 0 && h.TActualExt != nil
+}
@@ -24,12 +24,12 @@ func TestHTTPClient_Connect_Success(t *testing.T) {
 	mockMessage := &transport.Message{
 		XMLName: xml.Name{Local: "Devices"},
 		Device: transport.Device{
-			ID:   "TEST-123",
-			Type: "EZR",
-			Name: "Test Device",
-			HeatAreas: []transport.HeatArea{
-				{Nr: 1, Name: "Room 1", TTarget: 22.0, TActual: 21.5},
-				{Nr: 2, Name: "Room 2", TTarget: 20.0, TActual: 19.5},
+			ID:   ptr("TEST-123"),
+			Type: ptr("EZR"),
+			Name: ptr("Test Device"),
+			HeatAreas: &[]transport.HeatArea{
+				{Nr: ptr(1), Name: ptr("Room 1"), TTarget: ptr(22.0), TActual: ptr(21.5)},
+				{Nr: ptr(2), Name: ptr("Room 2"), TTarget: ptr(20.0), TActual: ptr(19.5)},
 			},
 		},
 	}
@@ -55,10 +55,10 @@ func TestHTTPClient_Connect_Success(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
-	assert.Equal(t, "TEST-123", result.Device.ID)
-	assert.Equal(t, "EZR", result.Device.Type)
-	assert.Equal(t, "Test Device", result.Device.Name)
-	assert.Len(t, result.Device.HeatAreas, 2)
+	assert.Equal(t, "TEST-123", *result.Device.ID)
+	assert.Equal(t, "EZR", *result.Device.Type)
+	assert.Equal(t, "Test Device", *result.Device.Name)
+	assert.Len(t, *result.Device.HeatAreas, 2)
 }
 
 func TestHTTPClient_Connect_InvalidXML(t *testing.T) {
@@ -123,9 +123,9 @@ func TestHTTPClient_Send_Success(t *testing.T) {
 
 	msg := &transport.Message{
 		Device: transport.Device{
-			ID: "TEST-123",
-			HeatAreas: []transport.HeatArea{
-				{Nr: 1, TTarget: 23.0},
+			ID: ptr("TEST-123"),
+			HeatAreas: &[]transport.HeatArea{
+				{Nr: ptr(1), TTarget: ptr(23.0)},
 			},
 		},
 	}
@@ -158,10 +158,10 @@ func TestHTTPClient_Send_VerifyXMLStructure(t *testing.T) {
 
 	sentMsg := &transport.Message{
 		Device: transport.Device{
-			ID: "DEVICE-456",
-			HeatAreas: []transport.HeatArea{
-				{Nr: 2, TTarget: 24.5},
-				{Nr: 3, TTarget: 19.0},
+			ID: ptr("DEVICE-456"),
+			HeatAreas: &[]transport.HeatArea{
+				{Nr: ptr(2), TTarget: ptr(24.5)},
+				{Nr: ptr(3), TTarget: ptr(19.0)},
 			},
 		},
 	}
@@ -170,12 +170,12 @@ func TestHTTPClient_Send_VerifyXMLStructure(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.NotNil(t, receivedMessage)
-	assert.Equal(t, "DEVICE-456", receivedMessage.Device.ID)
-	assert.Len(t, receivedMessage.Device.HeatAreas, 2)
-	assert.Equal(t, 2, receivedMessage.Device.HeatAreas[0].Nr)
-	assert.Equal(t, 24.5, receivedMessage.Device.HeatAreas[0].TTarget)
-	assert.Equal(t, 3, receivedMessage.Device.HeatAreas[1].Nr)
-	assert.Equal(t, 19.0, receivedMessage.Device.HeatAreas[1].TTarget)
+	assert.Equal(t, "DEVICE-456", *receivedMessage.Device.ID)
+	assert.Len(t, *receivedMessage.Device.HeatAreas, 2)
+	assert.Equal(t, 2, *(*receivedMessage.Device.HeatAreas)[0].Nr)
+	assert.Equal(t, 24.5, *(*receivedMessage.Device.HeatAreas)[0].TTarget)
+	assert.Equal(t, 3, *(*receivedMessage.Device.HeatAreas)[1].Nr)
+	assert.Equal(t, 19.0, *(*receivedMessage.Device.HeatAreas)[1].TTarget)
 }
 
 func TestHTTPClient_Connect_InvalidHostname(t *testing.T) {
@@ -192,7 +192,7 @@ func TestHTTPClient_Send_InvalidHostname(t *testing.T) {
 
 	msg := &transport.Message{
 		Device: transport.Device{
-			ID: "TEST",
+			ID: ptr("TEST"),
 		},
 	}
 
@@ -228,7 +228,7 @@ func TestHTTPClient_Send_ServerUnavailable(t *testing.T) {
 	client := NewHTTPClient(hostname)
 
 	msg := &transport.Message{
-		Device: transport.Device{ID: "TEST"},
+		Device: transport.Device{ID: ptr("TEST")},
 	}
 
 	err := client.Send(msg)
@@ -241,24 +241,24 @@ func TestHTTPClient_Connect_ComplexDevice(t *testing.T) {
 	mockMessage := &transport.Message{
 		XMLName: xml.Name{Local: "Devices"},
 		Device: transport.Device{
-			ID:       "COMPLEX-123",
-			Type:     "EZR",
-			Name:     "Complex Device",
-			DateTime: "2025-12-23 10:00:00",
-			HeatAreas: []transport.HeatArea{
+			ID:       ptr("COMPLEX-123"),
+			Type:     ptr("EZR"),
+			Name:     ptr("Complex Device"),
+			DateTime: ptr("2025-12-23 10:00:00"),
+			HeatAreas: &[]transport.HeatArea{
 				{
-					Nr:         1,
-					Name:       "Living Room",
-					TTarget:    22.0,
-					TActual:    21.5,
-					TTargetMin: 15.0,
-					TTargetMax: 30.0,
-					Mode:       1,
-					State:      1,
+					Nr:         ptr(1),
+					Name:       ptr("Living Room"),
+					TTarget:    ptr(22.0),
+					TActual:    ptr(21.5),
+					TTargetMin: ptr(15.0),
+					TTargetMax: ptr(30.0),
+					Mode:       ptr(1),
+					State:      ptr(1),
 				},
 			},
-			HeatCtrls: []transport.HeatCtrl{
-				{Nr: 1, InUse: 1, HeatAreaNr: 1, Actor: 50},
+			HeatCtrls: &[]transport.HeatCtrl{
+				{Nr: ptr(1), InUse: ptr(1), HeatAreaNr: ptr(1), Actor: ptr(50)},
 			},
 		},
 	}
@@ -278,8 +278,12 @@ func TestHTTPClient_Connect_ComplexDevice(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
-	assert.Equal(t, "COMPLEX-123", result.Device.ID)
-	assert.Len(t, result.Device.HeatAreas, 1)
-	assert.Equal(t, "Living Room", result.Device.HeatAreas[0].Name)
-	assert.Len(t, result.Device.HeatCtrls, 1)
+	assert.Equal(t, "COMPLEX-123", *result.Device.ID)
+	assert.Len(t, *result.Device.HeatAreas, 1)
+	assert.Equal(t, "Living Room", *(*result.Device.HeatAreas)[0].Name)
+	assert.Len(t, *result.Device.HeatCtrls, 1)
+}
+
+func ptr[T any](v T) *T {
+	return &v
 }
@@ -2,11 +2,13 @@ package mock
 
 import (
 	"reflect"
+	"sync"
 
 	"github.com/chrishrb/ezr2mqtt/transport"
 )
 
 type MockClient struct {
+	sync.Mutex
 	// currentMessage stores the current state of the device
 	currentMessage *transport.Message
 }
@@ -18,21 +20,155 @@ func NewMockClient() *MockClient {
 }
 
 func (c *MockClient) Connect() (*transport.Message, error) {
-	return c.currentMessage, nil
+	c.Lock()
+	defer c.Unlock()
+
+	// Return a copy so that callers can't mutate the device state
+	msg := &transport.Message{}
+	mergeMessages(msg, c.currentMessage)
+	return msg, nil
 }
 
 func (c *MockClient) Send(msg *transport.Message) error {
+	c.Lock()
+	defer c.Unlock()
+
 	// Mutate the current message by updating only the fields that are present in msg
 	mergeMessages(c.currentMessage, msg)
 	return nil
 }
 
+func ptr[T any](v T) *T {
+	return &v
+}
+
 // createMockMessage creates a transport.Message with mock data
 func createMockMessage() *transport.Message {
-	return &transport.Message{}
+	return &transport.Message{
+		Device: transport.Device{
+			ID:   ptr("MOCK-12345"),
+			Type: ptr("EZR"),
+			Name: ptr("Mock Device"),
+
+			ErrorCount: ptr(0),
+			DateTime:   ptr("2025-01-01T12:00:00"),
+			DayOfWeek:  ptr(3),
+			TimeZone:   ptr(1),
+			NTPSync:    ptr(1),
+
+			VersSWSTM: ptr("02.02"),
+			VersSWETH: ptr("02.10"),
+			VersHW:    ptr("00.00"),
+
+			Mode:         ptr(1),
+			Cooling:      ptr(0),
+			Changeover:   ptr(1),
+			SummerWinter: ptr(0),
+
+			ChangeoverFunc: &transport.ChangeoverFunc{Mode: ptr(0)},
+
+			Antifreeze:     ptr(1),
+			AntifreezeTemp: ptr(5.0),
+
+			EcoDiff:       ptr(2.0),
+			EcoInputMode:  ptr(0),
+			EcoInputState: ptr(0),
+			SmartStart:    ptr(0),
+			FirstOpenTime: ptr(10),
+
+			THeatVacation: ptr(15.0),
+			Vacation: &transport.Vacation{
+				State:     ptr(0),
+				StartDate: ptr("01.01.2025"),
+				StartTime: ptr("00:00"),
+				EndDate:   ptr("01.01.2025"),
+				EndTime:   ptr("00:00"),
+			},
+
+			Network: &transport.Network{
+				MAC:           ptr("00:11:22:33:44:55"),
+				DHCP:          ptr(1),
+				IPv4Actual:    ptr("192.168.1.100"),
+				NetmaskActual: ptr("255.255.255.0"),
+				Gateway:       ptr("192.168.1.1"),
+				DNS:           ptr("192.168.1.1"),
+			},
+
+			Program: &transport.Program{
+				ShiftPrograms: &[]transport.ShiftProgram{
+					{Nr: ptr(1), ShiftingTime: ptr(1), Start: ptr("06:00"), End: ptr("22:00")},
+					{Nr: ptr(1), ShiftingTime: ptr(2), Start: ptr("00:00"), End: ptr("00:00")},
+					{Nr: ptr(1), ShiftingTime: ptr(3), Start: ptr("00:00"), End: ptr("00:00")},
+					{Nr: ptr(2), ShiftingTime: ptr(1), Start: ptr("06:00"), End: ptr("08:00")},
+					{Nr: ptr(2), ShiftingTime: ptr(2), Start: ptr("17:00"), End: ptr("22:00")},
+					{Nr: ptr(2), ShiftingTime: ptr(3), Start: ptr("00:00"), End: ptr("00:00")},
+				},
+			},
+
+			HeatAreas: &[]transport.HeatArea{
+				{
+					Nr:                 ptr(1),
+					Name:               ptr("Living Room"),
+					Mode:               ptr(1),
+					State:              ptr(0),
+					TActual:            ptr(22.5),
+					TActualExt:         ptr(24.5),
+					SensorExt:          ptr(1),
+					Offset:             ptr(0.0),
+					TTarget:            ptr(22.0),
+					TTargetMin:         ptr(5.0),
+					TTargetMax:         ptr(30.0),
+					THeatDay:           ptr(22.0),
+					THeatNight:         ptr(18.0),
+					TCoolDay:           ptr(24.0),
+					TCoolNight:         ptr(26.0),
+					Party:              ptr(0),
+					PartyRemainingTime: ptr(0),
+					Presence:           ptr(0),
+					ProgramWeek:        ptr(1),
+					ProgramWeekend:     ptr(2),
+					IsLocked:           ptr(0),
+					LockCode:           ptr("1234"),
+					LockAvailable:      ptr(1),
+				},
+				{
+					Nr:                 ptr(2),
+					Name:               ptr("Bedroom"),
+					Mode:               ptr(1),
+					State:              ptr(0),
+					TActual:            ptr(19.5),
+					SensorExt:          ptr(0),
+					Offset:             ptr(-0.5),
+					TTarget:            ptr(20.0),
+					TTargetMin:         ptr(5.0),
+					TTargetMax:         ptr(30.0),
+					THeatDay:           ptr(20.0),
+					THeatNight:         ptr(16.0),
+					TCoolDay:           ptr(24.0),
+					TCoolNight:         ptr(26.0),
+					Party:              ptr(0),
+					PartyRemainingTime: ptr(0),
+					Presence:           ptr(0),
+					ProgramWeek:        ptr(1),
+					ProgramWeekend:     ptr(2),
+					IsLocked:           ptr(0),
+					LockCode:           ptr("0000"),
+					LockAvailable:      ptr(0),
+				},
+			},
+			HeatCtrls: &[]transport.HeatCtrl{
+				{Nr: ptr(1), InUse: ptr(1), HeatAreaNr: ptr(1), Actor: ptr(1), ActorPercent: ptr(60), State: ptr(0)},
+				{Nr: ptr(2), InUse: ptr(1), HeatAreaNr: ptr(2), Actor: ptr(30), ActorPercent: ptr(30), State: ptr(0)},
+			},
+			IODevices: &[]transport.IODevice{
+				{Nr: ptr(1), Type: ptr(1), ID: ptr(10001), VersHW: ptr("01.00"), VersSW: ptr("01.04"), HeatAreaNr: ptr(1), SignalStrength: ptr(80), Battery: ptr(0), State: ptr(0), ComError: ptr(0), IsOn: ptr(1)},
+				{Nr: ptr(2), Type: ptr(1), ID: ptr(10002), VersHW: ptr("01.00"), VersSW: ptr("01.04"), HeatAreaNr: ptr(2), SignalStrength: ptr(35), Battery: ptr(1), State: ptr(0), ComError: ptr(0), IsOn: ptr(1)},
+			},
+		},
+	}
 }
 
-// mergeMessages updates target with values from source where source has non-zero values
+// mergeMessages updates target with values from source where source has non-nil values
 func mergeMessages(target, source *transport.Message) {
 	if source == nil || target == nil {
 		return
@@ -42,8 +178,8 @@ func mergeMessages(target, source *transport.Message) {
 	mergeStructs(reflect.ValueOf(&target.Device).Elem(), reflect.ValueOf(&source.Device).Elem())
 }
 
-// mergeStructs recursively merges source struct into target struct
-// Only non-zero values from source are copied to target
+// mergeStructs recursively merges source into target. Nil pointers in
+// source are skipped, everything else is copied to target.
 func mergeStructs(target, source reflect.Value) {
 	if !target.IsValid() || !source.IsValid() {
 		return
@@ -62,133 +198,72 @@ func mergeStructs(target, source reflect.Value) {
 			mergeStructs(targetField, sourceField)
 		}
 
-	case reflect.Slice:
-		// For slices, replace the entire slice if source is non-empty
-		if source.Len() > 0 {
-			// Match slice elements by their 'Nr' field if it exists
-			if source.Len() > 0 && source.Index(0).Kind() == reflect.Struct {
-				mergeSlicesByNr(target, source)
-			} else {
-				target.Set(source)
-			}
+	case reflect.Ptr:
+		if source.IsNil() {
+			return
 		}
-
-	case reflect.String:
-		if source.String() != "" {
-			target.SetString(source.String())
+		if target.IsNil() {
+			target.Set(reflect.New(source.Type().Elem()))
 		}
+		mergeStructs(target.Elem(), source.Elem())
 
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		// Only update if source has a non-zero value
-		if source.Int() != 0 {
-			target.SetInt(source.Int())
+	case reflect.Slice:
+		// Match slice elements by their 'Nr' field if it exists
+		if source.Type().Elem().Kind() == reflect.Struct {
+			mergeSlicesByNr(target, source)
+		} else {
+			target.Set(reflect.AppendSlice(reflect.MakeSlice(source.Type(), 0, source.Len()), source))
 		}
 
-	case reflect.Float32, reflect.Float64:
-		// Only update if source has a non-zero value
-		if source.Float() != 0.0 {
-			target.SetFloat(source.Float())
-		}
+	default:
+		target.Set(source)
 	}
 }
 
-// mergeSlicesByNr merges slices by matching elements with the same 'Nr' field
+// mergeSlicesByNr merges slices by matching elements with the same 'Nr' field,
+// and the same 'ShiftingTime' field if the elements have one
 func mergeSlicesByNr(target, source reflect.Value) {
-	if source.Len() == 0 {
-		return
-	}
-
-	// Check if elements have Nr field
-	elemType := source.Type().Elem()
-	if elemType.Kind() != reflect.Struct {
-		return
-	}
-
-	nrField, hasNr := elemType.FieldByName("Nr")
-	if !hasNr || nrField.Type.Kind() != reflect.Int {
+	if _, hasNr := source.Type().Elem().FieldByName("Nr"); !hasNr {
 		// If no Nr field, just replace the entire slice
-		target.Set(source)
+		target.Set(reflect.MakeSlice(source.Type(), 0, source.Len()))
+		for i := 0; i < source.Len(); i++ {
+			target.Set(reflect.Append(target, reflect.New(source.Type().Elem()).Elem()))
+			mergeStructs(target.Index(i), source.Index(i))
+		}
 		return
 	}
 
-	// Create a map of target elements by Nr
-	targetMap := make(map[int]reflect.Value)
-	for i := 0; i < target.Len(); i++ {
-		elem := target.Index(i)
-		nr := int(elem.FieldByName("Nr").Int())
-		targetMap[nr] = elem
-	}
-
-	// Merge source elements into target
 	for i := 0; i < source.Len(); i++ {
 		sourceElem := source.Index(i)
-		nr := int(sourceElem.FieldByName("Nr").Int())
+		key := elemKey(sourceElem)
 
-		if targetElem, exists := targetMap[nr]; exists {
-			// Update existing element - use special merge for elements matched by Nr
-			mergeStructsByNr(targetElem, sourceElem)
-		} else {
+		found := false
+		for j := 0; j < target.Len(); j++ {
+			if targetElem := target.Index(j); elemKey(targetElem) == key {
+				mergeStructs(targetElem, sourceElem)
+				found = true
+				break
+			}
+		}
+
+		if !found {
 			// Append new element
-			target.Set(reflect.Append(target, sourceElem))
+			target.Set(reflect.Append(target, reflect.New(sourceElem.Type()).Elem()))
+			mergeStructs(target.Index(target.Len()-1), sourceElem)
 		}
 	}
 }
 
-// mergeStructsByNr merges structs that were matched by Nr field
-// For these structs, we merge non-zero values, plus specific fields that should allow zero
-func mergeStructsByNr(target, source reflect.Value) {
-	if !target.IsValid() || !source.IsValid() {
-		return
-	}
-
-	if source.Kind() != reflect.Struct {
-		return
-	}
-
-	// Fields that should be merged even when zero
-	// These are fields where 0 is a valid meaningful value
-	zeroAllowedFields := map[string]bool{
-		"Mode":  true, // Heat area mode: 0=auto, 1=day, 2=night
-		"State": true, // Various state fields where 0 is valid
-		"Actor": true, // Actor percentage can be 0
-	}
-
-	for i := 0; i < source.NumField(); i++ {
-		sourceField := source.Field(i)
-		targetField := target.Field(i)
-		fieldName := source.Type().Field(i).Name
-
-		if !targetField.CanSet() {
-			continue
-		}
-
-		// Skip the Nr field itself to avoid changing the identifier
-		if fieldName == "Nr" {
-			continue
-		}
+// elemKey returns the values of the 'Nr' and 'ShiftingTime' fields, -1 if
+// they aren't set
+func elemKey(elem reflect.Value) [2]int {
+	return [2]int{intField(elem, "Nr"), intField(elem, "ShiftingTime")}
+}
 
-		// For fields in Nr-matched structs, apply different rules
-		switch sourceField.Kind() {
-		case reflect.Struct:
-			mergeStructs(targetField, sourceField)
-		case reflect.Slice:
-			if sourceField.Len() > 0 {
-				targetField.Set(sourceField)
-			}
-		case reflect.String:
-			if sourceField.String() != "" {
-				targetField.SetString(sourceField.String())
-			}
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			// Always set if non-zero, or if it's a field that allows zero values
-			if sourceField.Int() != 0 || zeroAllowedFields[fieldName] {
-				targetField.SetInt(sourceField.Int())
-			}
-		case reflect.Float32, reflect.Float64:
-			// Always set if non-zero, or if it's a field that allows zero values
-			if sourceField.Float() != 0.0 || zeroAllowedFields[fieldName] {
-				targetField.SetFloat(sourceField.Float())
-			}
-		}
+func intField(elem reflect.Value, name string) int {
+	f := elem.FieldByName(name)
+	if !f.IsValid() || f.IsNil() {
+		return -1
 	}
+	return int(f.Elem().Int())
 }
@@ -32,31 +32,46 @@ func TestConnect(t *testing.T) {
 	}
 
 	// Verify basic fields are populated
-	if msg.Device.ID != "MOCK-12345" {
-		t.Errorf("Expected Device.ID to be 'MOCK-12345', got '%s'", msg.Device.ID)
+	if *msg.Device.ID != "MOCK-12345" {
+		t.Errorf("Expected Device.ID to be 'MOCK-12345', got '%s'", *msg.Device.ID)
 	}
 
-	if msg.Device.Type != "EZR" {
-		t.Errorf("Expected Device.Type to be 'EZR', got '%s'", msg.Device.Type)
+	if *msg.Device.Type != "EZR" {
+		t.Errorf("Expected Device.Type to be 'EZR', got '%s'", *msg.Device.Type)
 	}
 
-	if msg.Device.Name != "Mock Device" {
-		t.Errorf("Expected Device.Name to be 'Mock Device', got '%s'", msg.Device.Name)
+	if *msg.Device.Name != "Mock Device" {
+		t.Errorf("Expected Device.Name to be 'Mock Device', got '%s'", *msg.Device.Name)
 	}
 
 	// Verify heat areas are populated
-	if len(msg.Device.HeatAreas) != 2 {
-		t.Errorf("Expected 2 heat areas, got %d", len(msg.Device.HeatAreas))
+	heatAreas := *msg.Device.HeatAreas
+	if len(heatAreas) != 2 {
+		t.Fatalf("Expected 2 heat areas, got %d", len(heatAreas))
 	}
 
-	if len(msg.Device.HeatAreas) > 0 {
-		if msg.Device.HeatAreas[0].Name != "Living Room" {
-			t.Errorf("Expected first heat area to be 'Living Room', got '%s'", msg.Device.HeatAreas[0].Name)
-		}
+	if *heatAreas[0].Name != "Living Room" {
+		t.Errorf("Expected first heat area to be 'Living Room', got '%s'", *heatAreas[0].Name)
+	}
 
-		if msg.Device.HeatAreas[0].TTarget != 22.0 {
-			t.Errorf("Expected first heat area TTarget to be 22.0, got %f", msg.Device.HeatAreas[0].TTarget)
-		}
+	if *heatAreas[0].TTarget != 22.0 {
+		t.Errorf("Expected first heat area TTarget to be 22.0, got %f", *heatAreas[0].TTarget)
+	}
+}
+
+func TestConnect_ReturnsCopy(t *testing.T) {
+	client := NewMockClient()
+
+	msg, _ := client.Connect()
+	*msg.Device.Name = "Changed"
+	(*msg.Device.HeatAreas)[0].TTarget = ptr(30.0)
+
+	if *client.currentMessage.Device.Name != "Mock Device" {
+		t.Errorf("Expected Name to remain 'Mock Device', got '%s'", *client.currentMessage.Device.Name)
+	}
+
+	if *(*client.currentMessage.Device.HeatAreas)[0].TTarget != 22.0 {
+		t.Errorf("Expected TTarget to remain 22.0, got %f", *(*client.currentMessage.Device.HeatAreas)[0].TTarget)
 	}
 }
 
@@ -65,12 +80,12 @@ func TestSend_UpdateSingleField(t *testing.T) {
 
 	// Get initial state
 	initial, _ := client.Connect()
-	initialName := initial.Device.Name
+	initialName := *initial.Device.Name
 
 	// Create a message with only one field updated
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			Mode: 2, // Change mode from 1 to 2
+			Mode: ptr(2), // Change mode from 1 to 2
 		},
 	}
 
@@ -80,17 +95,17 @@ func TestSend_UpdateSingleField(t *testing.T) {
 	}
 
 	// Verify the mode was updated
-	if client.currentMessage.Device.Mode != 2 {
-		t.Errorf("Expected Mode to be 2, got %d", client.currentMessage.Device.Mode)
+	if *client.currentMessage.Device.Mode != 2 {
+		t.Errorf("Expected Mode to be 2, got %d", *client.currentMessage.Device.Mode)
 	}
 
 	// Verify other fields remain unchanged
-	if client.currentMessage.Device.Name != initialName {
-		t.Errorf("Expected Name to remain '%s', got '%s'", initialName, client.currentMessage.Device.Name)
+	if *client.currentMessage.Device.Name != initialName {
+		t.Errorf("Expected Name to remain '%s', got '%s'", initialName, *client.currentMessage.Device.Name)
 	}
 
-	if client.currentMessage.Device.ID != "MOCK-12345" {
-		t.Errorf("Expected ID to remain 'MOCK-12345', got '%s'", client.currentMessage.Device.ID)
+	if *client.currentMessage.Device.ID != "MOCK-12345" {
+		t.Errorf("Expected ID to remain 'MOCK-12345', got '%s'", *client.currentMessage.Device.ID)
 	}
 }
 
@@ -99,14 +114,14 @@ func TestSend_UpdateNestedStruct(t *testing.T) {
 
 	// Get initial network config
 	initial, _ := client.Connect()
-	initialMAC := initial.Device.Network.MAC
-	initialDHCP := initial.Device.Network.DHCP
+	initialMAC := *initial.Device.Network.MAC
+	initialDHCP := *initial.Device.Network.DHCP
 
 	// Update only the IPv4 address
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			Network: transport.Network{
-				IPv4Actual: "192.168.2.100",
+			Network: &transport.Network{
+				IPv4Actual: ptr("192.168.2.100"),
 			},
 		},
 	}
@@ -116,18 +131,20 @@ func TestSend_UpdateNestedStruct(t *testing.T) {
 		t.Fatalf("Send returned error: %v", err)
 	}
 
+	network := client.currentMessage.Device.Network
+
 	// Verify IPv4 was updated
-	if client.currentMessage.Device.Network.IPv4Actual != "192.168.2.100" {
-		t.Errorf("Expected IPv4Actual to be '192.168.2.100', got '%s'", client.currentMessage.Device.Network.IPv4Actual)
+	if *network.IPv4Actual != "192.168.2.100" {
+		t.Errorf("Expected IPv4Actual to be '192.168.2.100', got '%s'", *network.IPv4Actual)
 	}
 
 	// Verify other network fields remain unchanged
-	if client.currentMessage.Device.Network.MAC != initialMAC {
-		t.Errorf("Expected MAC to remain '%s', got '%s'", initialMAC, client.currentMessage.Device.Network.MAC)
+	if *network.MAC != initialMAC {
+		t.Errorf("Expected MAC to remain '%s', got '%s'", initialMAC, *network.MAC)
 	}
 
-	if client.currentMessage.Device.Network.DHCP != initialDHCP {
-		t.Errorf("Expected DHCP to remain %d, got %d", initialDHCP, client.currentMessage.Device.Network.DHCP)
+	if *network.DHCP != initialDHCP {
+		t.Errorf("Expected DHCP to remain %d, got %d", initialDHCP, *network.DHCP)
 	}
 }
 
@@ -136,16 +153,16 @@ func TestSend_UpdateHeatAreaByNr(t *testing.T) {
 
 	// Get initial state
 	initial, _ := client.Connect()
-	initialLivingRoomTarget := initial.Device.HeatAreas[0].TTarget
-	initialBedroomTarget := initial.Device.HeatAreas[1].TTarget
+	initialLivingRoomTarget := *(*initial.Device.HeatAreas)[0].TTarget
+	initialBedroomTarget := *(*initial.Device.HeatAreas)[1].TTarget
 
 	// Update only heat area #1 (Living Room) target temperature
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			HeatAreas: []transport.HeatArea{
+			HeatAreas: &[]transport.HeatArea{
 				{
-					Nr:      1,
-					TTarget: 24.5,
+					Nr:      ptr(1),
+					TTarget: ptr(24.5),
 				},
 			},
 		},
@@ -156,37 +173,39 @@ func TestSend_UpdateHeatAreaByNr(t *testing.T) {
 		t.Fatalf("Send returned error: %v", err)
 	}
 
+	heatAreas := *client.currentMessage.Device.HeatAreas
+
 	// Verify heat area #1 was updated
-	if len(client.currentMessage.Device.HeatAreas) < 2 {
-		t.Fatalf("Expected at least 2 heat areas, got %d", len(client.currentMessage.Device.HeatAreas))
+	if len(heatAreas) < 2 {
+		t.Fatalf("Expected at least 2 heat areas, got %d", len(heatAreas))
 	}
 
-	heatArea1 := findHeatAreaByNr(client.currentMessage.Device.HeatAreas, 1)
+	heatArea1 := findHeatAreaByNr(heatAreas, 1)
 	if heatArea1 == nil {
 		t.Fatal("Heat area #1 not found")
 	}
 
-	if heatArea1.TTarget != 24.5 {
-		t.Errorf("Expected heat area #1 TTarget to be 24.5, got %f", heatArea1.TTarget)
+	if *heatArea1.TTarget != 24.5 {
+		t.Errorf("Expected heat area #1 TTarget to be 24.5, got %f", *heatArea1.TTarget)
 	}
 
 	// Verify heat area #1's other fields remain unchanged
-	if heatArea1.Name != "Living Room" {
-		t.Errorf("Expected heat area #1 Name to remain 'Living Room', got '%s'", heatArea1.Name)
+	if *heatArea1.Name != "Living Room" {
+		t.Errorf("Expected heat area #1 Name to remain 'Living Room', got '%s'", *heatArea1.Name)
 	}
 
 	// Verify heat area #2 remains completely unchanged
-	heatArea2 := findHeatAreaByNr(client.currentMessage.Device.HeatAreas, 2)
+	heatArea2 := findHeatAreaByNr(heatAreas, 2)
 	if heatArea2 == nil {
 		t.Fatal("Heat area #2 not found")
 	}
 
-	if heatArea2.TTarget != initialBedroomTarget {
-		t.Errorf("Expected heat area #2 TTarget to remain %f, got %f", initialBedroomTarget, heatArea2.TTarget)
+	if *heatArea2.TTarget != initialBedroomTarget {
+		t.Errorf("Expected heat area #2 TTarget to remain %f, got %f", initialBedroomTarget, *heatArea2.TTarget)
 	}
 
-	if heatArea2.Name != "Bedroom" {
-		t.Errorf("Expected heat area #2 Name to remain 'Bedroom', got '%s'", heatArea2.Name)
+	if *heatArea2.Name != "Bedroom" {
+		t.Errorf("Expected heat area #2 Name to remain 'Bedroom', got '%s'", *heatArea2.Name)
 	}
 
 	// Verify initial living room target was different
@@ -201,14 +220,14 @@ func TestSend_UpdateMultipleHeatAreas(t *testing.T) {
 	// Update both heat areas
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			HeatAreas: []transport.HeatArea{
+			HeatAreas: &[]transport.HeatArea{
 				{
-					Nr:      1,
-					TTarget: 23.0,
+					Nr:      ptr(1),
+					TTarget: ptr(23.0),
 				},
 				{
-					Nr:      2,
-					TTarget: 19.5,
+					Nr:      ptr(2),
+					TTarget: ptr(19.5),
 				},
 			},
 		},
@@ -219,23 +238,25 @@ func TestSend_UpdateMultipleHeatAreas(t *testing.T) {
 		t.Fatalf("Send returned error: %v", err)
 	}
 
+	heatAreas := *client.currentMessage.Device.HeatAreas
+
 	// Verify both heat areas were updated
-	heatArea1 := findHeatAreaByNr(client.currentMessage.Device.HeatAreas, 1)
+	heatArea1 := findHeatAreaByNr(heatAreas, 1)
 	if heatArea1 == nil {
 		t.Fatal("Heat area #1 not found")
 	}
 
-	if heatArea1.TTarget != 23.0 {
-		t.Errorf("Expected heat area #1 TTarget to be 23.0, got %f", heatArea1.TTarget)
+	if *heatArea1.TTarget != 23.0 {
+		t.Errorf("Expected heat area #1 TTarget to be 23.0, got %f", *heatArea1.TTarget)
 	}
 
-	heatArea2 := findHeatAreaByNr(client.currentMessage.Device.HeatAreas, 2)
+	heatArea2 := findHeatAreaByNr(heatAreas, 2)
 	if heatArea2 == nil {
 		t.Fatal("Heat area #2 not found")
 	}
 
-	if heatArea2.TTarget != 19.5 {
-		t.Errorf("Expected heat area #2 TTarget to be 19.5, got %f", heatArea2.TTarget)
+	if *heatArea2.TTarget != 19.5 {
+		t.Errorf("Expected heat area #2 TTarget to be 19.5, got %f", *heatArea2.TTarget)
 	}
 }
 
@@ -244,16 +265,16 @@ func TestSend_AddNewHeatArea(t *testing.T) {
 
 	// Get initial count
 	initial, _ := client.Connect()
-	initialCount := len(initial.Device.HeatAreas)
+	initialCount := len(*initial.Device.HeatAreas)
 
 	// Add a new heat area
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			HeatAreas: []transport.HeatArea{
+			HeatAreas: &[]transport.HeatArea{
 				{
-					Nr:      3,
-					Name:    "Kitchen",
-					TTarget: 21.0,
+					Nr:      ptr(3),
+					Name:    ptr("Kitchen"),
+					TTarget: ptr(21.0),
 				},
 			},
 		},
@@ -264,22 +285,24 @@ func TestSend_AddNewHeatArea(t *testing.T) {
 		t.Fatalf("Send returned error: %v", err)
 	}
 
+	heatAreas := *client.currentMessage.Device.HeatAreas
+
 	// Verify new heat area was added
-	if len(client.currentMessage.Device.HeatAreas) != initialCount+1 {
-		t.Errorf("Expected %d heat areas, got %d", initialCount+1, len(client.currentMessage.Device.HeatAreas))
+	if len(heatAreas) != initialCount+1 {
+		t.Errorf("Expected %d heat areas, got %d", initialCount+1, len(heatAreas))
 	}
 
-	heatArea3 := findHeatAreaByNr(client.currentMessage.Device.HeatAreas, 3)
+	heatArea3 := findHeatAreaByNr(heatAreas, 3)
 	if heatArea3 == nil {
 		t.Fatal("Heat area #3 not found")
 	}
 
-	if heatArea3.Name != "Kitchen" {
-		t.Errorf("Expected heat area #3 Name to be 'Kitchen', got '%s'", heatArea3.Name)
+	if *heatArea3.Name != "Kitchen" {
+		t.Errorf("Expected heat area #3 Name to be 'Kitchen', got '%s'", *heatArea3.Name)
 	}
 
-	if heatArea3.TTarget != 21.0 {
-		t.Errorf("Expected heat area #3 TTarget to be 21.0, got %f", heatArea3.TTarget)
+	if *heatArea3.TTarget != 21.0 {
+		t.Errorf("Expected heat area #3 TTarget to be 21.0, got %f", *heatArea3.TTarget)
 	}
 }
 
@@ -289,7 +312,7 @@ func TestSend_UpdateFloatValue(t *testing.T) {
 	// Update a float value
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			AntifreezeTemp: 7.5,
+			AntifreezeTemp: ptr(7.5),
 		},
 	}
 
@@ -299,8 +322,8 @@ func TestSend_UpdateFloatValue(t *testing.T) {
 	}
 
 	// Verify float was updated
-	if client.currentMessage.Device.AntifreezeTemp != 7.5 {
-		t.Errorf("Expected AntifreezeTemp to be 7.5, got %f", client.currentMessage.Device.AntifreezeTemp)
+	if *client.currentMessage.Device.AntifreezeTemp != 7.5 {
+		t.Errorf("Expected AntifreezeTemp to be 7.5, got %f", *client.currentMessage.Device.AntifreezeTemp)
 	}
 }
 
@@ -310,7 +333,7 @@ func TestSend_UpdateStringValue(t *testing.T) {
 	// Update a string value
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			Name: "Updated Device Name",
+			Name: ptr("Updated Device Name"),
 		},
 	}
 
@@ -320,8 +343,8 @@ func TestSend_UpdateStringValue(t *testing.T) {
 	}
 
 	// Verify string was updated
-	if client.currentMessage.Device.Name != "Updated Device Name" {
-		t.Errorf("Expected Name to be 'Updated Device Name', got '%s'", client.currentMessage.Device.Name)
+	if *client.currentMessage.Device.Name != "Updated Device Name" {
+		t.Errorf("Expected Name to be 'Updated Device Name', got '%s'", *client.currentMessage.Device.Name)
 	}
 }
 
@@ -331,7 +354,7 @@ func TestSend_MultipleSequentialUpdates(t *testing.T) {
 	// First update
 	updateMsg1 := &transport.Message{
 		Device: transport.Device{
-			Mode: 2,
+			Mode: ptr(2),
 		},
 	}
 
@@ -340,14 +363,14 @@ func TestSend_MultipleSequentialUpdates(t *testing.T) {
 		t.Fatalf("First Send returned error: %v", err)
 	}
 
-	if client.currentMessage.Device.Mode != 2 {
-		t.Errorf("After first update, expected Mode to be 2, got %d", client.currentMessage.Device.Mode)
+	if *client.currentMessage.Device.Mode != 2 {
+		t.Errorf("After first update, expected Mode to be 2, got %d", *client.currentMessage.Device.Mode)
 	}
 
 	// Second update
 	updateMsg2 := &transport.Message{
 		Device: transport.Device{
-			Cooling: 1,
+			Cooling: ptr(1),
 		},
 	}
 
@@ -356,28 +379,27 @@ func TestSend_MultipleSequentialUpdates(t *testing.T) {
 		t.Fatalf("Second Send returned error: %v", err)
 	}
 
-	if client.currentMessage.Device.Cooling != 1 {
-		t.Errorf("After second update, expected Cooling to be 1, got %d", client.currentMessage.Device.Cooling)
+	if *client.currentMessage.Device.Cooling != 1 {
+		t.Errorf("After second update, expected Cooling to be 1, got %d", *client.currentMessage.Device.Cooling)
 	}
 
 	// Verify first update persisted
-	if client.currentMessage.Device.Mode != 2 {
-		t.Errorf("After second update, expected Mode to still be 2, got %d", client.currentMessage.Device.Mode)
+	if *client.currentMessage.Device.Mode != 2 {
+		t.Errorf("After second update, expected Mode to still be 2, got %d", *client.currentMessage.Device.Mode)
 	}
 }
 
-func TestSend_ZeroValuesDoNotOverwrite(t *testing.T) {
+func TestSend_NilValuesDoNotOverwrite(t *testing.T) {
 	client := NewMockClient()
 
 	// Get initial mode
 	initial, _ := client.Connect()
-	initialMode := initial.Device.Mode
+	initialMode := *initial.Device.Mode
 
-	// Try to send a message with zero mode (should not overwrite)
+	// Send a message without mode (should not overwrite)
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			Mode: 0,
-			Name: "Updated Name",
+			Name: ptr("Updated Name"),
 		},
 	}
 
@@ -386,14 +408,14 @@ func TestSend_ZeroValuesDoNotOverwrite(t *testing.T) {
 		t.Fatalf("Send returned error: %v", err)
 	}
 
-	// Verify mode was NOT updated (because 0 is a zero value)
-	if client.currentMessage.Device.Mode != initialMode {
-		t.Errorf("Expected Mode to remain %d, got %d", initialMode, client.currentMessage.Device.Mode)
+	// Verify mode was NOT updated
+	if *client.currentMessage.Device.Mode != initialMode {
+		t.Errorf("Expected Mode to remain %d, got %d", initialMode, *client.currentMessage.Device.Mode)
 	}
 
 	// Verify name WAS updated
-	if client.currentMessage.Device.Name != "Updated Name" {
-		t.Errorf("Expected Name to be 'Updated Name', got '%s'", client.currentMessage.Device.Name)
+	if *client.currentMessage.Device.Name != "Updated Name" {
+		t.Errorf("Expected Name to be 'Updated Name', got '%s'", *client.currentMessage.Device.Name)
 	}
 }
 
@@ -402,23 +424,23 @@ func TestSend_UpdateHeatAreaMode_ZeroValue(t *testing.T) {
 
 	// Get initial state - heat area 1 has Mode=1 (day mode)
 	initial, _ := client.Connect()
-	heatArea1 := findHeatAreaByNr(initial.Device.HeatAreas, 1)
+	heatArea1 := findHeatAreaByNr(*initial.Device.HeatAreas, 1)
 	if heatArea1 == nil {
 		t.Fatal("Heat area #1 not found in initial state")
 	}
 
-	if heatArea1.Mode != 1 {
-		t.Fatalf("Expected initial Mode to be 1, got %d", heatArea1.Mode)
+	if *heatArea1.Mode != 1 {
+		t.Fatalf("Expected initial Mode to be 1, got %d", *heatArea1.Mode)
 	}
 
 	// Update heat area mode to 0 (auto mode)
-	// This is the critical test case - zero values SHOULD be applied for Nr-matched elements
+	// Zero values that are set must be applied
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			HeatAreas: []transport.HeatArea{
+			HeatAreas: &[]transport.HeatArea{
 				{
-					Nr:   1,
-					Mode: 0, // Setting to 0 (auto mode)
+					Nr:   ptr(1),
+					Mode: ptr(0), // Setting to 0 (auto mode)
 				},
 			},
 		},
@@ -430,22 +452,22 @@ func TestSend_UpdateHeatAreaMode_ZeroValue(t *testing.T) {
 	}
 
 	// Verify mode WAS updated to 0 (even though it's a zero value)
-	heatArea1After := findHeatAreaByNr(client.currentMessage.Device.HeatAreas, 1)
+	heatArea1After := findHeatAreaByNr(*client.currentMessage.Device.HeatAreas, 1)
 	if heatArea1After == nil {
 		t.Fatal("Heat area #1 not found after update")
 	}
 
-	if heatArea1After.Mode != 0 {
-		t.Errorf("Expected Mode to be updated to 0 (auto), got %d", heatArea1After.Mode)
+	if *heatArea1After.Mode != 0 {
+		t.Errorf("Expected Mode to be updated to 0 (auto), got %d", *heatArea1After.Mode)
 	}
 
 	// Verify other fields remain unchanged
-	if heatArea1After.Name != "Living Room" {
-		t.Errorf("Expected Name to remain 'Living Room', got '%s'", heatArea1After.Name)
+	if *heatArea1After.Name != "Living Room" {
+		t.Errorf("Expected Name to remain 'Living Room', got '%s'", *heatArea1After.Name)
 	}
 
-	if heatArea1After.TTarget != 22.0 {
-		t.Errorf("Expected TTarget to remain 22.0, got %f", heatArea1After.TTarget)
+	if *heatArea1After.TTarget != 22.0 {
+		t.Errorf("Expected TTarget to remain 22.0, got %f", *heatArea1After.TTarget)
 	}
 }
 
@@ -467,10 +489,10 @@ func TestSend_UpdateHeatAreaMode_AllModes(t *testing.T) {
 			// Update heat area 2 mode
 			updateMsg := &transport.Message{
 				Device: transport.Device{
-					HeatAreas: []transport.HeatArea{
+					HeatAreas: &[]transport.HeatArea{
 						{
-							Nr:   2,
-							Mode: tc.mode,
+							Nr:   ptr(2),
+							Mode: ptr(tc.mode),
 						},
 					},
 				},
@@ -482,18 +504,18 @@ func TestSend_UpdateHeatAreaMode_AllModes(t *testing.T) {
 			}
 
 			// Verify mode was updated
-			heatArea2 := findHeatAreaByNr(client.currentMessage.Device.HeatAreas, 2)
+			heatArea2 := findHeatAreaByNr(*client.currentMessage.Device.HeatAreas, 2)
 			if heatArea2 == nil {
 				t.Fatal("Heat area #2 not found")
 			}
 
-			if heatArea2.Mode != tc.expectedMode {
-				t.Errorf("Expected Mode to be %d, got %d", tc.expectedMode, heatArea2.Mode)
+			if *heatArea2.Mode != tc.expectedMode {
+				t.Errorf("Expected Mode to be %d, got %d", tc.expectedMode, *heatArea2.Mode)
 			}
 
 			// Verify other fields remain unchanged
-			if heatArea2.Name != "Bedroom" {
-				t.Errorf("Expected Name to remain 'Bedroom', got '%s'", heatArea2.Name)
+			if *heatArea2.Name != "Bedroom" {
+				t.Errorf("Expected Name to remain 'Bedroom', got '%s'", *heatArea2.Name)
 			}
 		})
 	}
@@ -504,7 +526,7 @@ func TestSend_NilMessage(t *testing.T) {
 
 	// Get initial state
 	initial, _ := client.Connect()
-	initialID := initial.Device.ID
+	initialID := *initial.Device.ID
 
 	// Send nil message (should not panic)
 	err := client.Send(nil)
@@ -513,7 +535,7 @@ func TestSend_NilMessage(t *testing.T) {
 	}
 
 	// Verify state unchanged
-	if client.currentMessage.Device.ID != initialID {
+	if *client.currentMessage.Device.ID != initialID {
 		t.Error("State should not change when sending nil message")
 	}
 }
@@ -524,10 +546,10 @@ func TestSend_UpdateHeatCtrlByNr(t *testing.T) {
 	// Update heat controller #1
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			HeatCtrls: []transport.HeatCtrl{
+			HeatCtrls: &[]transport.HeatCtrl{
 				{
-					Nr:    1,
-					Actor: 75,
+					Nr:    ptr(1),
+					Actor: ptr(75),
 				},
 			},
 		},
@@ -538,29 +560,31 @@ func TestSend_UpdateHeatCtrlByNr(t *testing.T) {
 		t.Fatalf("Send returned error: %v", err)
 	}
 
+	heatCtrls := *client.currentMessage.Device.HeatCtrls
+
 	// Verify heat controller #1 was updated
-	heatCtrl1 := findHeatCtrlByNr(client.currentMessage.Device.HeatCtrls, 1)
+	heatCtrl1 := findHeatCtrlByNr(heatCtrls, 1)
 	if heatCtrl1 == nil {
 		t.Fatal("Heat controller #1 not found")
 	}
 
-	if heatCtrl1.Actor != 75 {
-		t.Errorf("Expected heat controller #1 Actor to be 75, got %d", heatCtrl1.Actor)
+	if *heatCtrl1.Actor != 75 {
+		t.Errorf("Expected heat controller #1 Actor to be 75, got %d", *heatCtrl1.Actor)
 	}
 
 	// Verify other fields remain unchanged
-	if heatCtrl1.HeatAreaNr != 1 {
-		t.Errorf("Expected heat controller #1 HeatAreaNr to remain 1, got %d", heatCtrl1.HeatAreaNr)
+	if *heatCtrl1.HeatAreaNr != 1 {
+		t.Errorf("Expected heat controller #1 HeatAreaNr to remain 1, got %d", *heatCtrl1.HeatAreaNr)
 	}
 
 	// Verify heat controller #2 remains unchanged
-	heatCtrl2 := findHeatCtrlByNr(client.currentMessage.Device.HeatCtrls, 2)
+	heatCtrl2 := findHeatCtrlByNr(heatCtrls, 2)
 	if heatCtrl2 == nil {
 		t.Fatal("Heat controller #2 not found")
 	}
 
-	if heatCtrl2.Actor != 30 {
-		t.Errorf("Expected heat controller #2 Actor to remain 30, got %d", heatCtrl2.Actor)
+	if *heatCtrl2.Actor != 30 {
+		t.Errorf("Expected heat controller #2 Actor to remain 30, got %d", *heatCtrl2.Actor)
 	}
 }
 
@@ -570,9 +594,9 @@ func TestSend_UpdateVacationStruct(t *testing.T) {
 	// Update vacation state
 	updateMsg := &transport.Message{
 		Device: transport.Device{
-			Vacation: transport.Vacation{
-				State:     1,
-				StartDate: "24.12.2025",
+			Vacation: &transport.Vacation{
+				State:     ptr(1),
+				StartDate: ptr("24.12.2025"),
 			},
 		},
 	}
@@ -582,18 +606,20 @@ func TestSend_UpdateVacationStruct(t *testing.T) {
 		t.Fatalf("Send returned error: %v", err)
 	}
 
+	vacation := client.currentMessage.Device.Vacation
+
 	// Verify vacation state was updated
-	if client.currentMessage.Device.Vacation.State != 1 {
-		t.Errorf("Expected Vacation.State to be 1, got %d", client.currentMessage.Device.Vacation.State)
+	if *vacation.State != 1 {
+		t.Errorf("Expected Vacation.State to be 1, got %d", *vacation.State)
 	}
 
-	if client.currentMessage.Device.Vacation.StartDate != "24.12.2025" {
-		t.Errorf("Expected Vacation.StartDate to be '24.12.2025', got '%s'", client.currentMessage.Device.Vacation.StartDate)
+	if *vacation.StartDate != "24.12.2025" {
+		t.Errorf("Expected Vacation.StartDate to be '24.12.2025', got '%s'", *vacation.StartDate)
 	}
 
 	// Verify other vacation fields remain unchanged
-	if client.currentMessage.Device.Vacation.StartTime != "00:00" {
-		t.Errorf("Expected Vacation.StartTime to remain '00:00', got '%s'", client.currentMessage.Device.Vacation.StartTime)
+	if *vacation.StartTime != "00:00" {
+		t.Errorf("Expected Vacation.StartTime to remain '00:00', got '%s'", *vacation.StartTime)
 	}
 }
 
@@ -601,7 +627,7 @@ func TestSend_UpdateVacationStruct(t *testing.T) {
 
 func findHeatAreaByNr(heatAreas []transport.HeatArea, nr int) *transport.HeatArea {
 	for i := range heatAreas {
-		if heatAreas[i].Nr == nr {
+		if *heatAreas[i].Nr == nr {
 			return &heatAreas[i]
 		}
 	}
@@ -610,7 +636,7 @@ func findHeatAreaByNr(heatAreas []transport.HeatArea, nr int) *transport.HeatAre
 
 func findHeatCtrlByNr(heatCtrls []transport.HeatCtrl, nr int) *transport.HeatCtrl {
 	for i := range heatCtrls {
-		if heatCtrls[i].Nr == nr {
+		if *heatCtrls[i].Nr == nr {
 			return &heatCtrls[i]
 		}
 	}
@@ -0,0 +1,27 @@
+package transport
+
+import "slices"
+
+// Slots returns the switching times reported for each program, ordered by
+// their number. Switching times with equal start and end are unused.
+func (p *Program) Slots() map[int][]ShiftProgram {
+	res := make(map[int][]ShiftProgram)
+	if p == nil || p.ShiftPrograms == nil {
+		return res
+	}
+	for _, s := range *p.ShiftPrograms {
+		if s.Nr == nil || s.ShiftingTime == nil {
+			continue
+		}
+		res[*s.Nr] = append(res[*s.Nr], s)
+	}
+	for _, slots := range res {
+		slices.SortFunc(slots, func(a, b ShiftProgram) int { return *a.ShiftingTime - *b.ShiftingTime })
+	}
+	return res
+}
+
+// Unused reports whether the switching time is not in use.
+func (s ShiftProgram) Unused() bool {
+	return s.Start == nil || s.End == nil || *s.Start == *s.End
+}